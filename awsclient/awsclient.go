@@ -0,0 +1,178 @@
+// Package awsclient builds the AWS clients of the plugin components from one
+// shared configuration, so they use the same account, region and retries.
+package awsclient
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/aws/retry"
+	"github.com/aws/aws-sdk-go-v2/service/acm"
+	"github.com/aws/aws-sdk-go-v2/service/cloudfront"
+	"github.com/aws/aws-sdk-go-v2/service/iam"
+	"github.com/aws/aws-sdk-go-v2/service/route53"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/sts"
+	"github.com/pilot-framework/aws-cloudfront-waypoint-plugin/cfront"
+	"github.com/pilot-framework/aws-cloudfront-waypoint-plugin/creds"
+)
+
+// Retry modes of the AWS SDK
+const (
+	RetryModeStandard = "standard"
+	RetryModeAdaptive = "adaptive"
+)
+
+// globalRegion is used for the global services when no region is configured
+const globalRegion = "us-east-1"
+
+// Settings configure the clients of a component.
+type Settings struct {
+	// Region of the bucket, the global services do not need one
+	Region      string
+	Credentials creds.Options
+
+	// "standard" or "adaptive", defaults to the SDK's standard mode
+	RetryMode string
+	// Attempts per request including the first, defaults to the SDK's 3
+	MaxAttempts int
+
+	S3Endpoint         string
+	ForcePathStyle     bool
+	CloudfrontEndpoint string
+}
+
+// Validate checks the settings taken from the plugin configuration.
+func (s Settings) Validate() error {
+	switch s.RetryMode {
+	case "", RetryModeStandard, RetryModeAdaptive:
+	default:
+		return fmt.Errorf("retry_mode must be %q or %q, got: %v", RetryModeStandard, RetryModeAdaptive, s.RetryMode)
+	}
+
+	if s.MaxAttempts < 0 {
+		return fmt.Errorf("max_attempts must not be negative, got: %v", s.MaxAttempts)
+	}
+
+	return s.Credentials.Validate()
+}
+
+// ValidateEndpoint checks that a custom endpoint is an absolute http(s) URL.
+// An empty endpoint uses the AWS default.
+func ValidateEndpoint(name string, endpoint string) error {
+	if endpoint == "" {
+		return nil
+	}
+
+	u, err := url.Parse(endpoint)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("%v must be an http or https URL, got: %v", name, endpoint)
+	}
+
+	return nil
+}
+
+// Factory creates clients that share credentials and retry behaviour.
+type Factory struct {
+	config   aws.Config
+	settings Settings
+}
+
+// New loads the AWS configuration for the settings.
+func New(c context.Context, s Settings) (*Factory, error) {
+	o := s.Credentials
+	o.Region = s.Region
+
+	cfg, err := creds.LoadConfig(c, o)
+	if err != nil {
+		return nil, err
+	}
+
+	if s.RetryMode != "" || s.MaxAttempts > 0 {
+		cfg.Retryer = retryer(s.RetryMode, s.MaxAttempts)
+	}
+
+	return &Factory{config: cfg, settings: s}, nil
+}
+
+func retryer(mode string, maxAttempts int) func() aws.Retryer {
+	standard := func(o *retry.StandardOptions) {
+		if maxAttempts > 0 {
+			o.MaxAttempts = maxAttempts
+		}
+	}
+
+	return func() aws.Retryer {
+		if mode == RetryModeAdaptive {
+			return retry.NewAdaptiveMode(func(o *retry.AdaptiveModeOptions) {
+				o.StandardOptions = append(o.StandardOptions, standard)
+			})
+		}
+
+		return retry.NewStandard(standard)
+	}
+}
+
+// Region returns the region the regional clients use.
+func (f *Factory) Region() string {
+	return f.config.Region
+}
+
+func (f *Factory) globalRegion(region *string) {
+	if *region == "" {
+		*region = globalRegion
+	}
+}
+
+// S3 returns a client for the bucket's region, pointed at the custom endpoint if set.
+func (f *Factory) S3() *s3.Client {
+	return s3.NewFromConfig(f.config, func(o *s3.Options) {
+		if f.settings.S3Endpoint != "" {
+			o.EndpointResolver = s3.EndpointResolverFromURL(f.settings.S3Endpoint)
+		}
+
+		// S3 stand-ins usually cannot serve buckets as subdomains
+		o.UsePathStyle = f.settings.ForcePathStyle
+	})
+}
+
+func (f *Factory) CloudFront() *cloudfront.Client {
+	return cloudfront.NewFromConfig(f.config, cfront.EndpointOptions(f.settings.CloudfrontEndpoint))
+}
+
+// Certificates returns a client for the region of CloudFront certificates.
+func (f *Factory) Certificates() *acm.Client {
+	return acm.NewFromConfig(f.config, func(o *acm.Options) {
+		o.Region = cfront.CertificateRegion
+	})
+}
+
+func (f *Factory) Route53() *route53.Client {
+	return route53.NewFromConfig(f.config, func(o *route53.Options) {
+		f.globalRegion(&o.Region)
+	})
+}
+
+func (f *Factory) STS() *sts.Client {
+	return sts.NewFromConfig(f.config, func(o *sts.Options) {
+		f.globalRegion(&o.Region)
+	})
+}
+
+func (f *Factory) IAM() *iam.Client {
+	return iam.NewFromConfig(f.config, func(o *iam.Options) {
+		f.globalRegion(&o.Region)
+	})
+}
+
+// AccountId returns the account the credentials belong to.
+func (f *Factory) AccountId(c context.Context) (string, error) {
+	identity, err := creds.GetCallerIdentity(c, f.STS(), &sts.GetCallerIdentityInput{})
+	if err != nil {
+		return "", err
+	}
+
+	return aws.ToString(identity.Account), nil
+}
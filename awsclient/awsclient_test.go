@@ -0,0 +1,178 @@
+package awsclient
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/aws/retry"
+	"github.com/aws/aws-sdk-go-v2/credentials"
+	"github.com/aws/aws-sdk-go-v2/service/acm"
+	"github.com/aws/aws-sdk-go-v2/service/iam"
+	"github.com/aws/aws-sdk-go-v2/service/route53"
+	"github.com/aws/aws-sdk-go-v2/service/sts"
+	"github.com/pilot-framework/aws-cloudfront-waypoint-plugin/creds"
+)
+
+func TestSettingsValidate(t *testing.T) {
+	tests := []struct {
+		name     string
+		settings Settings
+		err      bool
+	}{
+		{"defaults", Settings{}, false},
+		{"standard", Settings{RetryMode: RetryModeStandard, MaxAttempts: 5}, false},
+		{"adaptive", Settings{RetryMode: RetryModeAdaptive}, false},
+		{"unknown retry mode", Settings{RetryMode: "legacy"}, true},
+		{"negative attempts", Settings{MaxAttempts: -1}, true},
+		{"role", Settings{Credentials: creds.Options{RoleArn: "arn:aws:iam::123456789012:role/deployer"}}, false},
+		{"invalid credentials", Settings{Credentials: creds.Options{ExternalId: "x"}}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.settings.Validate(); (err != nil) != tt.err {
+				t.Errorf("err = %v, want error %v", err, tt.err)
+			}
+		})
+	}
+}
+
+func TestValidateEndpoint(t *testing.T) {
+	tests := []struct {
+		endpoint string
+		err      bool
+	}{
+		{"", false},
+		{"http://localhost:4566", false},
+		{"https://s3.example.com", false},
+		{"localhost:4566", true},
+		{"ftp://example.com", true},
+		{"http://", true},
+		{"://example.com", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.endpoint, func(t *testing.T) {
+			err := ValidateEndpoint("s3_endpoint", tt.endpoint)
+			if (err != nil) != tt.err {
+				t.Fatalf("err = %v, want error %v", err, tt.err)
+			}
+			if err != nil && !strings.Contains(err.Error(), "s3_endpoint") {
+				t.Errorf("error does not name the setting: %v", err)
+			}
+		})
+	}
+}
+
+func TestRetryer(t *testing.T) {
+	tests := []struct {
+		mode        string
+		maxAttempts int
+		adaptive    bool
+		attempts    int
+	}{
+		{"", 0, false, retry.DefaultMaxAttempts},
+		{RetryModeStandard, 5, false, 5},
+		{RetryModeAdaptive, 0, true, retry.DefaultMaxAttempts},
+		{RetryModeAdaptive, 7, true, 7},
+	}
+
+	for _, tt := range tests {
+		r := retryer(tt.mode, tt.maxAttempts)()
+
+		_, adaptive := r.(*retry.AdaptiveMode)
+		if adaptive != tt.adaptive {
+			t.Errorf("%q: retryer = %T", tt.mode, r)
+		}
+		if r.MaxAttempts() != tt.attempts {
+			t.Errorf("%q, %d: max attempts = %d, want %d", tt.mode, tt.maxAttempts, r.MaxAttempts(), tt.attempts)
+		}
+	}
+}
+
+func TestNew(t *testing.T) {
+	// keep the shared config files of the machine out of the test
+	dir := t.TempDir()
+	t.Setenv("AWS_CONFIG_FILE", filepath.Join(dir, "config"))
+	t.Setenv("AWS_SHARED_CREDENTIALS_FILE", filepath.Join(dir, "credentials"))
+
+	ctx := context.Background()
+
+	f, err := New(ctx, Settings{Region: "eu-west-1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if f.Region() != "eu-west-1" {
+		t.Errorf("region = %v", f.Region())
+	}
+	if f.config.Retryer != nil {
+		t.Error("retryer is set without retry settings")
+	}
+
+	f, err = New(ctx, Settings{RetryMode: RetryModeAdaptive, MaxAttempts: 5})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r := f.config.Retryer(); r.MaxAttempts() != 5 {
+		t.Errorf("max attempts = %d, want 5", r.MaxAttempts())
+	}
+}
+
+// scopeRecorder records the signing region of every request and fails it
+type scopeRecorder struct {
+	regions []string
+}
+
+func (s *scopeRecorder) Do(req *http.Request) (*http.Response, error) {
+	// Credential=AKID/<date>/<region>/<service>/aws4_request
+	scope := strings.SplitN(req.Header.Get("Authorization"), "Credential=", 2)
+	if len(scope) == 2 {
+		s.regions = append(s.regions, strings.Split(scope[1], "/")[2])
+	}
+
+	return nil, errors.New("recorded")
+}
+
+func TestGlobalRegion(t *testing.T) {
+	tests := []struct {
+		region string
+		want   []string
+	}{
+		// without a region the global services use us-east-1
+		{"", []string{"us-east-1", "us-east-1", "us-east-1", "us-east-1"}},
+		// STS is regional, IAM and Route 53 are signed for us-east-1 anyway
+		// and certificates for CloudFront are always in us-east-1
+		{"eu-west-1", []string{"eu-west-1", "us-east-1", "us-east-1", "us-east-1"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.region, func(t *testing.T) {
+			ctx := context.Background()
+			recorder := &scopeRecorder{}
+
+			f := &Factory{config: aws.Config{
+				Region:      tt.region,
+				Credentials: credentials.NewStaticCredentialsProvider("AKID", "SECRET", ""),
+				HTTPClient:  recorder,
+				Retryer: func() aws.Retryer {
+					return aws.NopRetryer{}
+				},
+			}}
+
+			f.STS().GetCallerIdentity(ctx, &sts.GetCallerIdentityInput{})
+			f.IAM().GetRole(ctx, &iam.GetRoleInput{RoleName: aws.String("deployer")})
+			f.Route53().ListHostedZones(ctx, &route53.ListHostedZonesInput{})
+			f.Certificates().ListCertificates(ctx, &acm.ListCertificatesInput{})
+
+			if !reflect.DeepEqual(recorder.regions, tt.want) {
+				t.Errorf("signed for %v, want %v", recorder.regions, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,400 @@
+package cfront
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/cloudfront"
+	"github.com/aws/aws-sdk-go-v2/service/cloudfront/types"
+)
+
+// MaxCanaryWeight is the largest share of requests CloudFront routes to a
+// staging distribution
+const MaxCanaryWeight = 0.15
+
+// CanaryHeaderPrefix is the prefix CloudFront requires of the header that
+// routes requests to a staging distribution
+const CanaryHeaderPrefix = "aws-cf-cd-"
+
+// CanaryOptions selects the requests served by the staging distribution,
+// either a share of all requests or those carrying a header
+type CanaryOptions struct {
+	Weight      float32
+	Header      string
+	HeaderValue string
+}
+
+// Canary is a staging distribution receiving traffic of its primary
+// distribution through a continuous deployment policy
+type Canary struct {
+	StagingId     string
+	StagingArn    string
+	StagingDomain string
+	PolicyId      string
+}
+
+func CopyDistribution(
+	c context.Context,
+	api CloudfrontAPI,
+	input *cloudfront.CopyDistributionInput,
+) (*cloudfront.CopyDistributionOutput, error) {
+	return api.CopyDistribution(c, input)
+}
+
+func UpdateDistributionWithStagingConfig(
+	c context.Context,
+	api CloudfrontAPI,
+	input *cloudfront.UpdateDistributionWithStagingConfigInput,
+) (*cloudfront.UpdateDistributionWithStagingConfigOutput, error) {
+	return api.UpdateDistributionWithStagingConfig(c, input)
+}
+
+func CreateContinuousDeploymentPolicy(
+	c context.Context,
+	api CloudfrontAPI,
+	input *cloudfront.CreateContinuousDeploymentPolicyInput,
+) (*cloudfront.CreateContinuousDeploymentPolicyOutput, error) {
+	return api.CreateContinuousDeploymentPolicy(c, input)
+}
+
+func GetContinuousDeploymentPolicy(
+	c context.Context,
+	api CloudfrontAPI,
+	input *cloudfront.GetContinuousDeploymentPolicyInput,
+) (*cloudfront.GetContinuousDeploymentPolicyOutput, error) {
+	return api.GetContinuousDeploymentPolicy(c, input)
+}
+
+func UpdateContinuousDeploymentPolicy(
+	c context.Context,
+	api CloudfrontAPI,
+	input *cloudfront.UpdateContinuousDeploymentPolicyInput,
+) (*cloudfront.UpdateContinuousDeploymentPolicyOutput, error) {
+	return api.UpdateContinuousDeploymentPolicy(c, input)
+}
+
+func DeleteContinuousDeploymentPolicy(
+	c context.Context,
+	api CloudfrontAPI,
+	input *cloudfront.DeleteContinuousDeploymentPolicyInput,
+) (*cloudfront.DeleteContinuousDeploymentPolicyOutput, error) {
+	return api.DeleteContinuousDeploymentPolicy(c, input)
+}
+
+// ContinuousDeploymentPolicyNotFound reports whether a call failed because the policy no longer exists
+func ContinuousDeploymentPolicyNotFound(err error) bool {
+	return strings.Contains(err.Error(), "NoSuchContinuousDeploymentPolicy")
+}
+
+// TrafficConfig routes the requests selected by the options to the staging distribution
+func (o CanaryOptions) TrafficConfig() *types.TrafficConfig {
+	if o.Header != "" {
+		return &types.TrafficConfig{
+			Type: types.ContinuousDeploymentPolicyTypeSingleHeader,
+			SingleHeaderConfig: &types.ContinuousDeploymentSingleHeaderConfig{
+				Header: aws.String(o.Header),
+				Value:  aws.String(o.HeaderValue),
+			},
+		}
+	}
+
+	return &types.TrafficConfig{
+		Type: types.ContinuousDeploymentPolicyTypeSingleWeight,
+		SingleWeightConfig: &types.ContinuousDeploymentSingleWeightConfig{
+			Weight: aws.Float32(o.Weight),
+		},
+	}
+}
+
+// SetOriginPath points the origin with the given ID to originPath and
+// reports whether it changed.
+func SetOriginPath(config *types.DistributionConfig, originId string, originPath string) bool {
+	for i, o := range config.Origins.Items {
+		if aws.ToString(o.Id) == originId && aws.ToString(o.OriginPath) != originPath {
+			config.Origins.Items[i].OriginPath = aws.String(originPath)
+			return true
+		}
+	}
+
+	return false
+}
+
+// UpdateOriginPath points the origin with the given ID of a distribution
+// to originPath, leaving the rest of its configuration alone.
+func UpdateOriginPath(c context.Context, api CloudfrontAPI, id string, originId string, originPath string) error {
+	current, err := GetDistributionConfig(c, api, &cloudfront.GetDistributionConfigInput{
+		Id: &id,
+	})
+	if err != nil {
+		return err
+	}
+
+	if !SetOriginPath(current.DistributionConfig, originId, originPath) {
+		return nil
+	}
+
+	_, err = UpdateDistribution(c, api, &cloudfront.UpdateDistributionInput{
+		Id:                 &id,
+		IfMatch:            current.ETag,
+		DistributionConfig: current.DistributionConfig,
+	})
+
+	return err
+}
+
+// StartCanary copies the primary distribution into a staging distribution
+// serving originPath and routes the requests selected by opts to it. When
+// it fails after the staging distribution was created the partial canary is
+// returned along with the error, so that it can be cleaned up.
+func StartCanary(
+	c context.Context,
+	api CloudfrontAPI,
+	primaryId string,
+	originId string,
+	originPath string,
+	opts CanaryOptions,
+) (*Canary, error) {
+	primary, err := GetDistributionConfig(c, api, &cloudfront.GetDistributionConfigInput{
+		Id: &primaryId,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	copied, err := CopyDistribution(c, api, &cloudfront.CopyDistributionInput{
+		PrimaryDistributionId: &primaryId,
+		CallerReference:       aws.String(fmt.Sprintf("pilot-staging-%v", time.Now().UnixNano())),
+		IfMatch:               primary.ETag,
+		Staging:               aws.Bool(true),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	canary := &Canary{
+		StagingId:     aws.ToString(copied.Distribution.Id),
+		StagingArn:    aws.ToString(copied.Distribution.ARN),
+		StagingDomain: aws.ToString(copied.Distribution.DomainName),
+	}
+
+	err = UpdateOriginPath(c, api, canary.StagingId, originId, originPath)
+	if err != nil {
+		return canary, err
+	}
+
+	policy, err := CreateContinuousDeploymentPolicy(c, api, &cloudfront.CreateContinuousDeploymentPolicyInput{
+		ContinuousDeploymentPolicyConfig: &types.ContinuousDeploymentPolicyConfig{
+			Enabled: aws.Bool(true),
+			StagingDistributionDnsNames: &types.StagingDistributionDnsNames{
+				Quantity: aws.Int32(1),
+				Items:    []string{canary.StagingDomain},
+			},
+			TrafficConfig: opts.TrafficConfig(),
+		},
+	})
+	if err != nil {
+		return canary, err
+	}
+
+	canary.PolicyId = aws.ToString(policy.ContinuousDeploymentPolicy.Id)
+
+	primary, err = GetDistributionConfig(c, api, &cloudfront.GetDistributionConfigInput{
+		Id: &primaryId,
+	})
+	if err != nil {
+		return canary, err
+	}
+
+	primary.DistributionConfig.ContinuousDeploymentPolicyId = aws.String(canary.PolicyId)
+
+	_, err = UpdateDistribution(c, api, &cloudfront.UpdateDistributionInput{
+		Id:                 &primaryId,
+		IfMatch:            primary.ETag,
+		DistributionConfig: primary.DistributionConfig,
+	})
+
+	return canary, err
+}
+
+// UpdateCanary points a running canary to originPath and applies the traffic
+// routing of opts.
+func UpdateCanary(
+	c context.Context,
+	api CloudfrontAPI,
+	canary *Canary,
+	originId string,
+	originPath string,
+	opts CanaryOptions,
+) error {
+	err := UpdateOriginPath(c, api, canary.StagingId, originId, originPath)
+	if err != nil {
+		return err
+	}
+
+	policy, err := GetContinuousDeploymentPolicy(c, api, &cloudfront.GetContinuousDeploymentPolicyInput{
+		Id: &canary.PolicyId,
+	})
+	if err != nil {
+		return err
+	}
+
+	config := policy.ContinuousDeploymentPolicy.ContinuousDeploymentPolicyConfig
+	config.Enabled = aws.Bool(true)
+	config.TrafficConfig = opts.TrafficConfig()
+
+	_, err = UpdateContinuousDeploymentPolicy(c, api, &cloudfront.UpdateContinuousDeploymentPolicyInput{
+		Id:                               &canary.PolicyId,
+		IfMatch:                          policy.ETag,
+		ContinuousDeploymentPolicyConfig: config,
+	})
+
+	return err
+}
+
+// FindCanary returns the canary attached to the primary distribution, or nil
+// if there is none. StagingId is empty if the staging distribution is gone.
+func FindCanary(c context.Context, api CloudfrontAPI, finder *DistributionFinder, primaryId string) (*Canary, error) {
+	primary, err := GetDistributionConfig(c, api, &cloudfront.GetDistributionConfigInput{
+		Id: &primaryId,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	policyId := aws.ToString(primary.DistributionConfig.ContinuousDeploymentPolicyId)
+	if policyId == "" {
+		return nil, nil
+	}
+
+	canary := &Canary{PolicyId: policyId}
+
+	policy, err := GetContinuousDeploymentPolicy(c, api, &cloudfront.GetContinuousDeploymentPolicyInput{
+		Id: &policyId,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	names := policy.ContinuousDeploymentPolicy.ContinuousDeploymentPolicyConfig.StagingDistributionDnsNames
+	if names == nil || len(names.Items) == 0 {
+		return canary, nil
+	}
+
+	summaries, err := finder.Summaries(c)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, s := range summaries {
+		if aws.ToBool(s.Staging) && aws.ToString(s.DomainName) == names.Items[0] {
+			canary.StagingId = aws.ToString(s.Id)
+			canary.StagingArn = aws.ToString(s.ARN)
+			canary.StagingDomain = aws.ToString(s.DomainName)
+			break
+		}
+	}
+
+	return canary, nil
+}
+
+// PromoteCanary copies the configuration of the staging distribution to the
+// primary distribution, which then serves what the canary did.
+func PromoteCanary(c context.Context, api CloudfrontAPI, primaryId string, canary *Canary) (*types.Distribution, error) {
+	primary, err := GetDistribution(c, api, &cloudfront.GetDistributionInput{
+		Id: &primaryId,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	staging, err := GetDistribution(c, api, &cloudfront.GetDistributionInput{
+		Id: &canary.StagingId,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	out, err := UpdateDistributionWithStagingConfig(c, api, &cloudfront.UpdateDistributionWithStagingConfigInput{
+		Id:                    &primaryId,
+		StagingDistributionId: &canary.StagingId,
+		IfMatch:               aws.String(aws.ToString(primary.ETag) + ", " + aws.ToString(staging.ETag)),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return out.Distribution, nil
+}
+
+// DetachCanary stops routing requests of the primary distribution to the
+// staging distribution and deletes the policy. It does nothing for parts
+// that are already gone.
+func DetachCanary(c context.Context, api CloudfrontAPI, primaryId string, policyId string) error {
+	primary, err := GetDistributionConfig(c, api, &cloudfront.GetDistributionConfigInput{
+		Id: &primaryId,
+	})
+	if err != nil && !DistributionNotFound(err) {
+		return err
+	}
+
+	if err == nil && aws.ToString(primary.DistributionConfig.ContinuousDeploymentPolicyId) == policyId {
+		primary.DistributionConfig.ContinuousDeploymentPolicyId = aws.String("")
+
+		_, err = UpdateDistribution(c, api, &cloudfront.UpdateDistributionInput{
+			Id:                 &primaryId,
+			IfMatch:            primary.ETag,
+			DistributionConfig: primary.DistributionConfig,
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	policy, err := GetContinuousDeploymentPolicy(c, api, &cloudfront.GetContinuousDeploymentPolicyInput{
+		Id: &policyId,
+	})
+	if err != nil {
+		if ContinuousDeploymentPolicyNotFound(err) {
+			return nil
+		}
+
+		return err
+	}
+
+	_, err = DeleteContinuousDeploymentPolicy(c, api, &cloudfront.DeleteContinuousDeploymentPolicyInput{
+		Id:      &policyId,
+		IfMatch: policy.ETag,
+	})
+	if err != nil && !ContinuousDeploymentPolicyNotFound(err) {
+		return err
+	}
+
+	return nil
+}
+
+// RetireStaging disables a staging distribution and marks it for deletion,
+// see ResumePendingDeletions. It does nothing if the distribution is gone.
+func RetireStaging(c context.Context, api CloudfrontAPI, id string, oacId string) error {
+	staging, err := GetDistribution(c, api, &cloudfront.GetDistributionInput{
+		Id: &id,
+	})
+	if err != nil {
+		if DistributionNotFound(err) {
+			return nil
+		}
+
+		return err
+	}
+
+	if aws.ToBool(staging.Distribution.DistributionConfig.Enabled) {
+		err = DisableDistribution(c, api, id)
+		if err != nil {
+			return err
+		}
+	}
+
+	return MarkPendingDeletion(c, api, aws.ToString(staging.Distribution.ARN), oacId)
+}
@@ -0,0 +1,104 @@
+package cfront
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/acm"
+	acmtypes "github.com/aws/aws-sdk-go-v2/service/acm/types"
+)
+
+// CertificateRegion is the only region CloudFront accepts ACM certificates from
+const CertificateRegion = "us-east-1"
+
+// DefaultMinimumProtocolVersion is the minimum TLS version viewers
+// must use when the distribution has custom domains
+const DefaultMinimumProtocolVersion = "TLSv1.2_2021"
+
+// Defines interface for needed ACM functions
+type CertificateAPI interface {
+	ListCertificates(
+		ctx context.Context,
+		input *acm.ListCertificatesInput,
+		optFns ...func(*acm.Options),
+	) (*acm.ListCertificatesOutput, error)
+}
+
+// FindCertificate returns the ARN of an issued certificate whose names cover
+// every domain. Certificates naming a domain exactly are preferred over
+// wildcards. api must be a client for CertificateRegion.
+func FindCertificate(c context.Context, api CertificateAPI, domains []string) (string, error) {
+	paginator := acm.NewListCertificatesPaginator(api, &acm.ListCertificatesInput{
+		CertificateStatuses: []acmtypes.CertificateStatus{acmtypes.CertificateStatusIssued},
+		// only RSA 2048 keys are listed unless asked otherwise
+		Includes: &acmtypes.Filters{
+			KeyTypes: []acmtypes.KeyAlgorithm{
+				acmtypes.KeyAlgorithmRsa2048,
+				acmtypes.KeyAlgorithmRsa3072,
+				acmtypes.KeyAlgorithmRsa4096,
+				acmtypes.KeyAlgorithmEcPrime256v1,
+			},
+		},
+	})
+
+	best := ""
+	bestExact := -1
+
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(c)
+		if err != nil {
+			return "", err
+		}
+
+		for _, cert := range page.CertificateSummaryList {
+			names := append([]string{aws.ToString(cert.DomainName)}, cert.SubjectAlternativeNameSummaries...)
+
+			exact, ok := coverage(names, domains)
+			if ok && exact > bestExact {
+				best = *cert.CertificateArn
+				bestExact = exact
+			}
+		}
+	}
+
+	if best == "" {
+		return "", fmt.Errorf("no issued certificate in %v covers %v", CertificateRegion, strings.Join(domains, ", "))
+	}
+
+	return best, nil
+}
+
+// coverage reports whether the certificate names cover every domain and how
+// many of them are matched exactly rather than by a wildcard.
+func coverage(names []string, domains []string) (int, bool) {
+	exact := 0
+
+	for _, domain := range domains {
+		domain = strings.ToLower(domain)
+		matched := false
+
+		for _, name := range names {
+			name = strings.ToLower(name)
+
+			if name == domain {
+				exact++
+				matched = true
+				break
+			}
+
+			// a wildcard only covers a single label
+			i := strings.Index(domain, ".")
+			if strings.HasPrefix(name, "*.") && i > 0 && name[1:] == domain[i:] {
+				matched = true
+			}
+		}
+
+		if !matched {
+			return 0, false
+		}
+	}
+
+	return exact, true
+}
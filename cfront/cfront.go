@@ -3,9 +3,11 @@ package cfront
 import (
 	"context"
 	"fmt"
+	"path"
 	"strings"
 	"time"
 
+	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/service/cloudfront"
 	"github.com/aws/aws-sdk-go-v2/service/cloudfront/types"
 )
@@ -42,6 +44,11 @@ type CloudfrontAPI interface {
 		input *cloudfront.ListTagsForResourceInput,
 		optFns ...func(*cloudfront.Options),
 	) (*cloudfront.ListTagsForResourceOutput, error)
+	TagResource(
+		ctx context.Context,
+		input *cloudfront.TagResourceInput,
+		optFns ...func(*cloudfront.Options),
+	) (*cloudfront.TagResourceOutput, error)
 	CreateDistributionWithTags(
 		ctx context.Context,
 		input *cloudfront.CreateDistributionWithTagsInput,
@@ -57,6 +64,81 @@ type CloudfrontAPI interface {
 		input *cloudfront.DeleteOriginRequestPolicyInput,
 		optFns ...func(*cloudfront.Options),
 	) (*cloudfront.DeleteOriginRequestPolicyOutput, error)
+	CreateInvalidation(
+		ctx context.Context,
+		input *cloudfront.CreateInvalidationInput,
+		optFns ...func(*cloudfront.Options),
+	) (*cloudfront.CreateInvalidationOutput, error)
+	GetInvalidation(
+		ctx context.Context,
+		input *cloudfront.GetInvalidationInput,
+		optFns ...func(*cloudfront.Options),
+	) (*cloudfront.GetInvalidationOutput, error)
+	CreateOriginAccessControl(
+		ctx context.Context,
+		input *cloudfront.CreateOriginAccessControlInput,
+		optFns ...func(*cloudfront.Options),
+	) (*cloudfront.CreateOriginAccessControlOutput, error)
+	GetOriginAccessControl(
+		ctx context.Context,
+		input *cloudfront.GetOriginAccessControlInput,
+		optFns ...func(*cloudfront.Options),
+	) (*cloudfront.GetOriginAccessControlOutput, error)
+	ListOriginAccessControls(
+		ctx context.Context,
+		input *cloudfront.ListOriginAccessControlsInput,
+		optFns ...func(*cloudfront.Options),
+	) (*cloudfront.ListOriginAccessControlsOutput, error)
+	DeleteOriginAccessControl(
+		ctx context.Context,
+		input *cloudfront.DeleteOriginAccessControlInput,
+		optFns ...func(*cloudfront.Options),
+	) (*cloudfront.DeleteOriginAccessControlOutput, error)
+	CopyDistribution(
+		ctx context.Context,
+		input *cloudfront.CopyDistributionInput,
+		optFns ...func(*cloudfront.Options),
+	) (*cloudfront.CopyDistributionOutput, error)
+	UpdateDistributionWithStagingConfig(
+		ctx context.Context,
+		input *cloudfront.UpdateDistributionWithStagingConfigInput,
+		optFns ...func(*cloudfront.Options),
+	) (*cloudfront.UpdateDistributionWithStagingConfigOutput, error)
+	CreateContinuousDeploymentPolicy(
+		ctx context.Context,
+		input *cloudfront.CreateContinuousDeploymentPolicyInput,
+		optFns ...func(*cloudfront.Options),
+	) (*cloudfront.CreateContinuousDeploymentPolicyOutput, error)
+	GetContinuousDeploymentPolicy(
+		ctx context.Context,
+		input *cloudfront.GetContinuousDeploymentPolicyInput,
+		optFns ...func(*cloudfront.Options),
+	) (*cloudfront.GetContinuousDeploymentPolicyOutput, error)
+	UpdateContinuousDeploymentPolicy(
+		ctx context.Context,
+		input *cloudfront.UpdateContinuousDeploymentPolicyInput,
+		optFns ...func(*cloudfront.Options),
+	) (*cloudfront.UpdateContinuousDeploymentPolicyOutput, error)
+	DeleteContinuousDeploymentPolicy(
+		ctx context.Context,
+		input *cloudfront.DeleteContinuousDeploymentPolicyInput,
+		optFns ...func(*cloudfront.Options),
+	) (*cloudfront.DeleteContinuousDeploymentPolicyOutput, error)
+}
+
+// DistributionOptions describes the distribution Pilot manages for a bucket
+type DistributionOptions struct {
+	Bucket string
+	Region string
+	// Origin Path that the CDN will treat as `/`
+	Root string
+	// ID of the Origin Access Control used to reach a private bucket through
+	// its REST endpoint. When empty the public website endpoint is used.
+	OriginAccessControlId string
+	// Custom domain names served with the ACM certificate
+	Aliases                []string
+	CertificateArn         string
+	MinimumProtocolVersion string
 }
 
 func GetDistribution(
@@ -131,14 +213,49 @@ func DeleteOrigin(
 	return api.DeleteOriginRequestPolicy(c, input)
 }
 
+// OriginPath returns the Origin Path serving root within the deployment
+// prefix of the bucket. Without a prefix root is used as is.
+func OriginPath(prefix string, root string) string {
+	if prefix == "" {
+		return root
+	}
+
+	return "/" + strings.Trim(path.Join(prefix, root), "/")
+}
+
 // This function will create the configuration needed to create a new origin
-func FormatOrigin(bucket string, region string, root string) types.Origin {
+func FormatOrigin(opts DistributionOptions) types.Origin {
+	var connAttempts int32 = 3
+	var connTimeout int32 = 10
+	var originId string = fmt.Sprintf("pilot-origin-%v", opts.Bucket)
+	var originPath string = opts.Root
+	origin := types.Origin{
+		ConnectionAttempts: &connAttempts,
+		ConnectionTimeout:  &connTimeout,
+		Id:                 &originId,
+		OriginPath:         &originPath,
+	}
+
+	// a private bucket is read through the S3 REST endpoint with signed requests
+	if opts.OriginAccessControlId != "" {
+		domainName := fmt.Sprintf("%v.s3.%v.amazonaws.com", opts.Bucket, opts.Region)
+		origin.DomainName = &domainName
+		origin.OriginAccessControlId = &opts.OriginAccessControlId
+		origin.S3OriginConfig = &types.S3OriginConfig{
+			OriginAccessIdentity: aws.String(""),
+		}
+
+		return origin
+	}
+
 	var http int32 = 80
 	var https int32 = 443
 	var keepAlive int32 = 5
 	var readTimeout int32 = 30
 
-	config := &types.CustomOriginConfig{
+	var domainName string = fmt.Sprintf("%v.s3-website.%v.amazonaws.com", opts.Bucket, opts.Region)
+	origin.DomainName = &domainName
+	origin.CustomOriginConfig = &types.CustomOriginConfig{
 		HTTPPort:               &http,
 		HTTPSPort:              &https,
 		OriginKeepaliveTimeout: &keepAlive,
@@ -146,28 +263,15 @@ func FormatOrigin(bucket string, region string, root string) types.Origin {
 		OriginReadTimeout:      &readTimeout,
 	}
 
-	var connAttempts int32 = 3
-	var connTimeout int32 = 10
-	var domainName string = fmt.Sprintf("%v.s3-website.%v.amazonaws.com", bucket, region)
-	var originId string = fmt.Sprintf("pilot-origin-%v", bucket)
-	var originPath string = root
-	origin := types.Origin{
-		ConnectionAttempts: &connAttempts,
-		ConnectionTimeout:  &connTimeout,
-		CustomOriginConfig: config,
-		DomainName:         &domainName,
-		Id:                 &originId,
-		OriginPath:         &originPath,
-	}
-
 	return origin
 }
 
 // This function will create the configuration input needed to create a new distribution
-func FormatDistributionInput(bucket string, region string, root string) *cloudfront.CreateDistributionWithTagsInput {
+func FormatDistributionInput(opts DistributionOptions) *cloudfront.CreateDistributionWithTagsInput {
 	// These are the tags that the distribution will have
 	// by default we include a bucket - bucket_name k/v to check if a distribution exists
-	tagKey := "bucket"
+	tagKey := BucketTagKey
+	bucket := opts.Bucket
 	items := [](types.Tag){
 		types.Tag{Key: &tagKey, Value: &bucket},
 	}
@@ -175,8 +279,10 @@ func FormatDistributionInput(bucket string, region string, root string) *cloudfr
 	callRef := fmt.Sprintf("pilot-ref-%v", time.Now()) // unique identifier for the request
 	comment := "This distribution was created via Pilot"
 	enabled := true
+	// the aliases get AAAA records as well as A records
+	ipv6 := true
 	var quantity int32 = 1
-	origin := FormatOrigin(bucket, region, root)
+	origin := FormatOrigin(opts)
 	// this is the ID for the Managed-CachingOptimized policy
 	cachePolicy := "658327ea-f89d-4fab-a63d-7e88639e58f6"
 
@@ -190,7 +296,8 @@ func FormatDistributionInput(bucket string, region string, root string) *cloudfr
 					ViewerProtocolPolicy: types.ViewerProtocolPolicyAllowAll,
 					CachePolicyId:        &cachePolicy,
 				},
-				Enabled: &enabled,
+				Enabled:       &enabled,
+				IsIPV6Enabled: &ipv6,
 				Origins: &types.Origins{
 					Quantity: &quantity,
 					Items:    [](types.Origin){origin},
@@ -202,43 +309,43 @@ func FormatDistributionInput(bucket string, region string, root string) *cloudfr
 		},
 	}
 
-	return input
-}
-
-func RemoveDistribution(id string, client *cloudfront.Client) {
-	status, err := PollStatus(id, client)
-	if err != nil {
-		panic(err)
+	// the REST endpoint has no index document of its own
+	if opts.OriginAccessControlId != "" {
+		input.DistributionConfigWithTags.DistributionConfig.DefaultRootObject = aws.String("index.html")
 	}
 
-	distInput := &cloudfront.GetDistributionInput{
-		Id: &id,
+	if len(opts.Aliases) > 0 {
+		config := input.DistributionConfigWithTags.DistributionConfig
+		config.Aliases = &types.Aliases{
+			Quantity: aws.Int32(int32(len(opts.Aliases))),
+			Items:    opts.Aliases,
+		}
+		config.ViewerCertificate = FormatViewerCertificate(opts)
 	}
 
-	dist, err := GetDistribution(context.TODO(), client, distInput)
-	if err != nil {
-		panic(err)
-	}
+	return input
+}
 
-	delInput := &cloudfront.DeleteDistributionInput{
-		Id:      &id,
-		IfMatch: dist.ETag,
+// FormatViewerCertificate serves the aliases over SNI with the ACM certificate
+func FormatViewerCertificate(opts DistributionOptions) *types.ViewerCertificate {
+	minProtocol := opts.MinimumProtocolVersion
+	if minProtocol == "" {
+		minProtocol = DefaultMinimumProtocolVersion
 	}
 
-	if status {
-		_, err = DeleteDistribution(context.TODO(), client, delInput)
-		if err != nil {
-			panic(err)
-		}
+	return &types.ViewerCertificate{
+		ACMCertificateArn:      aws.String(opts.CertificateArn),
+		SSLSupportMethod:       types.SSLSupportMethodSniOnly,
+		MinimumProtocolVersion: types.MinimumProtocolVersion(minProtocol),
 	}
 }
 
-func DisableDistribution(id string, client *cloudfront.Client) error {
+func DisableDistribution(c context.Context, client CloudfrontAPI, id string) error {
 	getCfgInput := &cloudfront.GetDistributionConfigInput{
 		Id: &id,
 	}
 
-	cfg, err := GetDistributionConfig(context.TODO(), client, getCfgInput)
+	cfg, err := GetDistributionConfig(c, client, getCfgInput)
 	if err != nil {
 		return err
 	}
@@ -253,7 +360,7 @@ func DisableDistribution(id string, client *cloudfront.Client) error {
 		IfMatch:            cfg.ETag,
 	}
 
-	_, err = UpdateDistribution(context.TODO(), client, updateCfgInput)
+	_, err = UpdateDistribution(c, client, updateCfgInput)
 	if err != nil {
 		return err
 	}
@@ -261,33 +368,24 @@ func DisableDistribution(id string, client *cloudfront.Client) error {
 	return nil
 }
 
-func PollStatus(id string, client *cloudfront.Client) (status bool, err error) {
-	distInput := &cloudfront.GetDistributionInput{
+// DeployTimeout is how long to wait for an updated distribution to deploy
+const DeployTimeout = 20 * time.Minute
+
+// PollStatus waits until the changes to the distribution are deployed, e.g.
+// after it was updated or disabled.
+func PollStatus(c context.Context, api CloudfrontAPI, id string, w *Waiter) error {
+	input := &cloudfront.GetDistributionInput{
 		Id: &id,
 	}
 
-	timedOut := true
-	status = false
-
-	// times out after five minutes
-	for i := 0; i < 30; i++ {
-		dist, getErr := GetDistribution(context.TODO(), client, distInput)
-		if getErr != nil {
-			err = getErr
-			return
-		}
-
-		if strings.ToLower(*dist.Distribution.Status) == "deployed" {
-			status = true
-			return
+	return w.Wait(c, func(c context.Context) (string, bool, error) {
+		dist, err := GetDistribution(c, api, input)
+		if err != nil {
+			return "", false, err
 		}
 
-		time.Sleep(time.Second * 10)
-	}
-
-	if timedOut {
-		err = fmt.Errorf("operation timed out after 10 minutes")
-	}
+		status := aws.ToString(dist.Distribution.Status)
 
-	return
+		return status, strings.ToLower(status) == "deployed", nil
+	})
 }
@@ -0,0 +1,177 @@
+package cfront
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/cloudfront"
+	"github.com/aws/aws-sdk-go-v2/service/cloudfront/types"
+)
+
+// Tags recording that a disabled distribution still has to be deleted,
+// along with the Origin Access Control to remove after it
+const (
+	PendingDeletionTagKey     = "pilot-pending-deletion"
+	OriginAccessControlTagKey = "pilot-origin-access-control"
+)
+
+// ReleaseTagKey is the tag recording the generation of the release that
+// last configured a distribution. Destroying an older release of the same
+// distribution leaves it in place.
+const ReleaseTagKey = "pilot-release"
+
+// DeleteWaitTimeout is how long to wait for a deleted distribution to be gone
+const DeleteWaitTimeout = 5 * time.Minute
+
+func TagDistribution(
+	c context.Context,
+	api CloudfrontAPI,
+	input *cloudfront.TagResourceInput,
+) (*cloudfront.TagResourceOutput, error) {
+	return api.TagResource(c, input)
+}
+
+// MarkPendingDeletion tags a distribution so that a later run deletes it
+// once it is disabled, together with its Origin Access Control if set.
+func MarkPendingDeletion(c context.Context, api CloudfrontAPI, arn string, oacId string) error {
+	items := []types.Tag{
+		{Key: aws.String(PendingDeletionTagKey), Value: aws.String("true")},
+	}
+
+	if oacId != "" {
+		items = append(items, types.Tag{Key: aws.String(OriginAccessControlTagKey), Value: aws.String(oacId)})
+	}
+
+	_, err := TagDistribution(c, api, &cloudfront.TagResourceInput{
+		Resource: &arn,
+		Tags:     &types.Tags{Items: items},
+	})
+
+	return err
+}
+
+// TagRelease records generation as the release the distribution serves.
+func TagRelease(c context.Context, api CloudfrontAPI, arn string, generation string) error {
+	_, err := TagDistribution(c, api, &cloudfront.TagResourceInput{
+		Resource: &arn,
+		Tags: &types.Tags{Items: []types.Tag{
+			{Key: aws.String(ReleaseTagKey), Value: aws.String(generation)},
+		}},
+	})
+
+	return err
+}
+
+// WaitForDeleted waits until a deleted distribution is gone, only then
+// its Origin Access Control can be removed.
+func WaitForDeleted(c context.Context, api CloudfrontAPI, id string, w *Waiter) error {
+	input := &cloudfront.GetDistributionInput{
+		Id: &id,
+	}
+
+	return w.Wait(c, func(c context.Context) (string, bool, error) {
+		dist, err := GetDistribution(c, api, input)
+		if err != nil {
+			if DistributionNotFound(err) {
+				return "Deleted", true, nil
+			}
+
+			return "", false, err
+		}
+
+		return aws.ToString(dist.Distribution.Status), false, nil
+	})
+}
+
+// RemoveDistribution deletes a distribution that has been disabled and
+// finished deploying.
+func RemoveDistribution(c context.Context, api CloudfrontAPI, id string) error {
+	dist, err := GetDistribution(c, api, &cloudfront.GetDistributionInput{
+		Id: &id,
+	})
+	if err != nil {
+		return err
+	}
+
+	_, err = DeleteDistribution(c, api, &cloudfront.DeleteDistributionInput{
+		Id:      &id,
+		IfMatch: dist.ETag,
+	})
+
+	return err
+}
+
+// DistributionNotFound reports whether a call failed because the distribution no longer exists
+func DistributionNotFound(err error) bool {
+	return strings.Contains(err.Error(), "NoSuchDistribution")
+}
+
+// OriginAccessControlInUse reports whether an Origin Access Control could not be
+// deleted because another distribution, e.g. a newer one for the bucket, uses it
+func OriginAccessControlInUse(err error) bool {
+	return strings.Contains(err.Error(), "OriginAccessControlInUse")
+}
+
+// OriginAccessControlNotFound reports whether a call failed because the Origin Access Control no longer exists
+func OriginAccessControlNotFound(err error) bool {
+	return strings.Contains(err.Error(), "NoSuchOriginAccessControl")
+}
+
+// ResumePendingDeletions deletes the distributions of the bucket marked by
+// MarkPendingDeletion that are disabled and deployed by now, and returns
+// their IDs. Others are left for a later run, those of other buckets to
+// their own. Failures do not stop the remaining deletions.
+func ResumePendingDeletions(c context.Context, api CloudfrontAPI, finder *DistributionFinder, bucket string) ([]string, error) {
+	summaries, err := finder.Summaries(c)
+	if err != nil {
+		return nil, err
+	}
+
+	deleted := []string{}
+	failed := []string{}
+
+	for _, s := range summaries {
+		if !hasBucketOrigin(s, bucket) || aws.ToBool(s.Enabled) || strings.ToLower(aws.ToString(s.Status)) != "deployed" {
+			continue
+		}
+
+		tags, err := finder.Tags(c, *s.ARN)
+		if err != nil {
+			return deleted, err
+		}
+
+		if tags[PendingDeletionTagKey] == "" {
+			continue
+		}
+
+		err = RemoveDistribution(c, api, *s.Id)
+		if err != nil && !DistributionNotFound(err) {
+			failed = append(failed, fmt.Sprintf("%v: %v", *s.Id, err))
+			continue
+		}
+
+		deleted = append(deleted, *s.Id)
+
+		if oacId := tags[OriginAccessControlTagKey]; oacId != "" {
+			err = WaitForDeleted(c, api, *s.Id, NewWaiter(DeleteWaitTimeout, nil))
+			if err != nil {
+				failed = append(failed, fmt.Sprintf("%v: %v", *s.Id, err))
+				continue
+			}
+
+			err = RemoveOriginAccessControl(c, api, oacId)
+			if err != nil && !OriginAccessControlInUse(err) && !OriginAccessControlNotFound(err) {
+				failed = append(failed, fmt.Sprintf("origin access control %v: %v", oacId, err))
+			}
+		}
+	}
+
+	if len(failed) > 0 {
+		return deleted, fmt.Errorf("could not delete %v", strings.Join(failed, "; "))
+	}
+
+	return deleted, nil
+}
@@ -0,0 +1,59 @@
+package cfront_test
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/pilot-framework/aws-cloudfront-waypoint-plugin/cfront"
+	"github.com/pilot-framework/aws-cloudfront-waypoint-plugin/fakes"
+)
+
+// createDistribution creates a distribution for the bucket and returns its ID
+func createDistribution(t *testing.T, client *fakes.CloudFront, bucket string) string {
+	t.Helper()
+
+	out, err := cfront.CreateDistribution(context.Background(), client, cfront.FormatDistributionInput(cfront.DistributionOptions{
+		Bucket: bucket,
+		Region: "us-east-1",
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return *out.Distribution.Id
+}
+
+func TestResumePendingDeletions(t *testing.T) {
+	ctx := context.Background()
+	client := fakes.NewCloudFront()
+
+	pending := map[string]string{}
+	for _, bucket := range []string{"site", "other"} {
+		id := createDistribution(t, client, bucket)
+
+		if err := cfront.DisableDistribution(ctx, client, id); err != nil {
+			t.Fatal(err)
+		}
+		if err := cfront.MarkPendingDeletion(ctx, client, client.Distribution(id).ARN, ""); err != nil {
+			t.Fatal(err)
+		}
+
+		pending[bucket] = id
+	}
+
+	// still enabled, not marked for deletion
+	serving := createDistribution(t, client, "site")
+
+	deleted, err := cfront.ResumePendingDeletions(ctx, client, cfront.NewDistributionFinder(client), "site")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !reflect.DeepEqual(deleted, []string{pending["site"]}) {
+		t.Errorf("deleted %v, want %v", deleted, pending["site"])
+	}
+	if ids := client.DistributionIds(); !reflect.DeepEqual(ids, []string{pending["other"], serving}) {
+		t.Errorf("distributions left = %v, want the other bucket's and the serving one", ids)
+	}
+}
@@ -0,0 +1,23 @@
+package cfront
+
+import (
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/cloudfront"
+)
+
+// SigningRegion is the region requests to the global CloudFront API are signed for
+const SigningRegion = "us-east-1"
+
+// EndpointOptions points a CloudFront client at a custom endpoint, e.g.
+// LocalStack. An empty endpoint keeps the AWS default.
+func EndpointOptions(endpoint string) func(*cloudfront.Options) {
+	return func(o *cloudfront.Options) {
+		if endpoint == "" {
+			return
+		}
+
+		o.EndpointResolver = cloudfront.EndpointResolverFromURL(endpoint, func(e *aws.Endpoint) {
+			e.SigningRegion = SigningRegion
+		})
+	}
+}
@@ -0,0 +1,169 @@
+package cfront
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/cloudfront"
+	"github.com/aws/aws-sdk-go-v2/service/cloudfront/types"
+)
+
+// BucketTagKey is the tag Pilot puts on a distribution to record the
+// bucket it serves
+const BucketTagKey = "bucket"
+
+// ErrDistributionNotFound is returned when no distribution belongs to a bucket
+var ErrDistributionNotFound = errors.New("distribution not found")
+
+// MultipleDistributionsError is returned when more than one distribution is
+// tagged with the same bucket and it is unclear which one to use
+type MultipleDistributionsError struct {
+	Bucket string
+	Ids    []string
+}
+
+func (e *MultipleDistributionsError) Error() string {
+	return fmt.Sprintf("found %d distributions for bucket %v: %v", len(e.Ids), e.Bucket, strings.Join(e.Ids, ", "))
+}
+
+// DistributionFinder looks up the distributions Pilot created. Listings and
+// tags are cached, so it should be created once per run and lookups after
+// the first do not query the account again. It is not safe for concurrent use.
+type DistributionFinder struct {
+	api       CloudfrontAPI
+	summaries []types.DistributionSummary
+	listed    bool
+	tags      map[string]map[string]string
+}
+
+func NewDistributionFinder(api CloudfrontAPI) *DistributionFinder {
+	return &DistributionFinder{
+		api:  api,
+		tags: map[string]map[string]string{},
+	}
+}
+
+// Summaries pages through every distribution in the account.
+func (f *DistributionFinder) Summaries(c context.Context) ([]types.DistributionSummary, error) {
+	if f.listed {
+		return f.summaries, nil
+	}
+
+	summaries := []types.DistributionSummary{}
+	input := &cloudfront.ListDistributionsInput{}
+
+	for {
+		out, err := GetAllDistributions(c, f.api, input)
+		if err != nil {
+			return nil, err
+		}
+
+		// the list is omitted entirely when the account has no distributions
+		if out.DistributionList == nil {
+			break
+		}
+
+		summaries = append(summaries, out.DistributionList.Items...)
+
+		if !aws.ToBool(out.DistributionList.IsTruncated) {
+			break
+		}
+
+		input.Marker = out.DistributionList.NextMarker
+	}
+
+	f.summaries = summaries
+	f.listed = true
+
+	return summaries, nil
+}
+
+// Tags returns the tags of the distribution with the given ARN by key.
+func (f *DistributionFinder) Tags(c context.Context, arn string) (map[string]string, error) {
+	if tags, ok := f.tags[arn]; ok {
+		return tags, nil
+	}
+
+	out, err := GetDistributionTags(c, f.api, &cloudfront.ListTagsForResourceInput{
+		Resource: &arn,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("retrieving tags for %v: %w", arn, err)
+	}
+
+	tags := map[string]string{}
+	if out.Tags != nil {
+		for _, tag := range out.Tags.Items {
+			tags[aws.ToString(tag.Key)] = aws.ToString(tag.Value)
+		}
+	}
+
+	f.tags[arn] = tags
+
+	return tags, nil
+}
+
+// Find returns the distribution tagged with the bucket that is neither
+// pending deletion nor a staging distribution. Only distributions with an
+// origin for the bucket are candidates, tags are fetched for those alone.
+// The error is ErrDistributionNotFound or a *MultipleDistributionsError when
+// there is not exactly one match.
+func (f *DistributionFinder) Find(c context.Context, bucket string) (*types.DistributionSummary, error) {
+	summaries, err := f.Summaries(c)
+	if err != nil {
+		return nil, err
+	}
+
+	matches := []types.DistributionSummary{}
+
+	for _, s := range summaries {
+		// staging distributions of canaries are copies of the one serving the bucket
+		if aws.ToBool(s.Staging) || !hasBucketOrigin(s, bucket) {
+			continue
+		}
+
+		tags, err := f.Tags(c, *s.ARN)
+		if err != nil {
+			return nil, err
+		}
+
+		// distributions waiting to be deleted no longer serve the bucket
+		if tags[BucketTagKey] == bucket && tags[PendingDeletionTagKey] == "" {
+			matches = append(matches, s)
+		}
+	}
+
+	switch len(matches) {
+	case 0:
+		return nil, ErrDistributionNotFound
+	case 1:
+		return &matches[0], nil
+	}
+
+	ids := make([]string, len(matches))
+	for i, m := range matches {
+		ids[i] = *m.Id
+	}
+
+	return nil, &MultipleDistributionsError{Bucket: bucket, Ids: ids}
+}
+
+// hasBucketOrigin reports whether the distribution has the origin Pilot
+// creates for the bucket, or any origin on one of the bucket's endpoints.
+func hasBucketOrigin(s types.DistributionSummary, bucket string) bool {
+	if s.Origins == nil {
+		return false
+	}
+
+	for _, o := range s.Origins.Items {
+		if aws.ToString(o.Id) == fmt.Sprintf("pilot-origin-%v", bucket) ||
+			strings.HasPrefix(aws.ToString(o.DomainName), bucket+".s3") {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,112 @@
+package cfront_test
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/service/cloudfront"
+	"github.com/pilot-framework/aws-cloudfront-waypoint-plugin/cfront"
+	"github.com/pilot-framework/aws-cloudfront-waypoint-plugin/fakes"
+)
+
+// listCounter counts the list calls and returns no list at all when the
+// account is empty, like CloudFront does
+type listCounter struct {
+	*fakes.CloudFront
+	lists int
+}
+
+func (l *listCounter) ListDistributions(ctx context.Context, input *cloudfront.ListDistributionsInput, optFns ...func(*cloudfront.Options)) (*cloudfront.ListDistributionsOutput, error) {
+	l.lists++
+
+	if len(l.DistributionIds()) == 0 {
+		return &cloudfront.ListDistributionsOutput{}, nil
+	}
+
+	return l.CloudFront.ListDistributions(ctx, input, optFns...)
+}
+
+func TestSummariesPaging(t *testing.T) {
+	ctx := context.Background()
+	client := &listCounter{CloudFront: fakes.NewCloudFront()}
+
+	// the fake lists 100 distributions per page
+	for i := 0; i < 250; i++ {
+		createDistribution(t, client.CloudFront, "site")
+	}
+
+	finder := cfront.NewDistributionFinder(client)
+
+	summaries, err := finder.Summaries(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ids := []string{}
+	for _, s := range summaries {
+		ids = append(ids, *s.Id)
+	}
+	if !reflect.DeepEqual(ids, client.DistributionIds()) {
+		t.Errorf("listed %d distributions, want %d", len(ids), len(client.DistributionIds()))
+	}
+	if client.lists != 3 {
+		t.Errorf("listed %d pages, want 3", client.lists)
+	}
+
+	// the listing is cached
+	if _, err := finder.Summaries(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if client.lists != 3 {
+		t.Errorf("listed again, %d pages", client.lists)
+	}
+}
+
+func TestSummariesWithoutDistributions(t *testing.T) {
+	client := &listCounter{CloudFront: fakes.NewCloudFront()}
+
+	summaries, err := cfront.NewDistributionFinder(client).Summaries(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(summaries) != 0 {
+		t.Errorf("summaries = %v", summaries)
+	}
+}
+
+func TestFind(t *testing.T) {
+	ctx := context.Background()
+	client := fakes.NewCloudFront()
+
+	site := createDistribution(t, client, "site")
+	createDistribution(t, client, "twin")
+	createDistribution(t, client, "twin")
+
+	pending := createDistribution(t, client, "gone")
+	if err := cfront.MarkPendingDeletion(ctx, client, client.Distribution(pending).ARN, ""); err != nil {
+		t.Fatal(err)
+	}
+
+	finder := cfront.NewDistributionFinder(client)
+
+	s, err := finder.Find(ctx, "site")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if *s.Id != site {
+		t.Errorf("found %v, want %v", *s.Id, site)
+	}
+
+	var multiple *cfront.MultipleDistributionsError
+	if _, err := finder.Find(ctx, "twin"); !errors.As(err, &multiple) || len(multiple.Ids) != 2 || multiple.Bucket != "twin" {
+		t.Errorf("err = %v, want two distributions for twin", err)
+	}
+
+	for _, bucket := range []string{"gone", "missing"} {
+		if _, err := finder.Find(ctx, bucket); !errors.Is(err, cfront.ErrDistributionNotFound) {
+			t.Errorf("%v: err = %v, want ErrDistributionNotFound", bucket, err)
+		}
+	}
+}
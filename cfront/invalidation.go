@@ -0,0 +1,126 @@
+package cfront
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"path"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/cloudfront"
+	"github.com/aws/aws-sdk-go-v2/service/cloudfront/types"
+)
+
+// MaxInvalidationPaths is the number of paths above which the whole
+// distribution is invalidated instead. CloudFront bills every path,
+// while a wildcard counts as one.
+const MaxInvalidationPaths = 30
+
+// InvalidateAll invalidates every path of a distribution
+const InvalidateAll = "/*"
+
+// InvalidationTimeout is how long to wait for an invalidation to complete
+const InvalidationTimeout = 15 * time.Minute
+
+func CreateInvalidation(
+	c context.Context,
+	api CloudfrontAPI,
+	input *cloudfront.CreateInvalidationInput,
+) (*cloudfront.CreateInvalidationOutput, error) {
+	return api.CreateInvalidation(c, input)
+}
+
+func GetInvalidation(
+	c context.Context,
+	api CloudfrontAPI,
+	input *cloudfront.GetInvalidationInput,
+) (*cloudfront.GetInvalidationOutput, error) {
+	return api.GetInvalidation(c, input)
+}
+
+// InvalidationPaths maps changed object keys to the viewer paths they are
+// served at through an origin path of root. Keys outside of root are not
+// served and skipped, index documents also invalidate their directory.
+// Falls back to InvalidateAll when there are too many, no keys yield no paths.
+func InvalidationPaths(keys []string, root string) []string {
+	prefix := strings.Trim(root, "/")
+	if prefix != "" {
+		prefix += "/"
+	}
+
+	set := map[string]bool{}
+
+	for _, key := range keys {
+		if !strings.HasPrefix(key, prefix) {
+			continue
+		}
+
+		p := "/" + strings.TrimPrefix(key, prefix)
+		set[p] = true
+
+		if path.Base(p) == "index.html" {
+			dir := strings.TrimSuffix(p, "index.html")
+			set[dir] = true
+			if dir != "/" {
+				set[strings.TrimSuffix(dir, "/")] = true
+			}
+		}
+	}
+
+	if len(set) > MaxInvalidationPaths {
+		return []string{InvalidateAll}
+	}
+
+	paths := []string{}
+	for p := range set {
+		// unsafe characters in paths must be URL encoded
+		paths = append(paths, (&url.URL{Path: p}).EscapedPath())
+	}
+
+	sort.Strings(paths)
+
+	return paths
+}
+
+// Invalidate creates an invalidation of paths and returns its ID.
+func Invalidate(c context.Context, api CloudfrontAPI, distributionId string, paths []string) (string, error) {
+	callRef := fmt.Sprintf("pilot-invalidation-%v", time.Now().UnixNano()) // unique identifier for the request
+
+	out, err := CreateInvalidation(c, api, &cloudfront.CreateInvalidationInput{
+		DistributionId: &distributionId,
+		InvalidationBatch: &types.InvalidationBatch{
+			CallerReference: &callRef,
+			Paths: &types.Paths{
+				Quantity: aws.Int32(int32(len(paths))),
+				Items:    paths,
+			},
+		},
+	})
+	if err != nil {
+		return "", err
+	}
+
+	return *out.Invalidation.Id, nil
+}
+
+// WaitForInvalidation waits until the invalidation is completed.
+func WaitForInvalidation(c context.Context, api CloudfrontAPI, distributionId string, id string, w *Waiter) error {
+	input := &cloudfront.GetInvalidationInput{
+		DistributionId: &distributionId,
+		Id:             &id,
+	}
+
+	return w.Wait(c, func(c context.Context) (string, bool, error) {
+		inv, err := GetInvalidation(c, api, input)
+		if err != nil {
+			return "", false, err
+		}
+
+		status := aws.ToString(inv.Invalidation.Status)
+
+		return status, strings.ToLower(status) == "completed", nil
+	})
+}
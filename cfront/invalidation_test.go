@@ -0,0 +1,43 @@
+package cfront_test
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+
+	"github.com/pilot-framework/aws-cloudfront-waypoint-plugin/cfront"
+)
+
+func TestInvalidationPaths(t *testing.T) {
+	many := []string{}
+	limit := []string{}
+	for i := 0; i <= cfront.MaxInvalidationPaths; i++ {
+		many = append(many, fmt.Sprintf("js/chunk-%02d.js", i))
+		limit = append(limit, fmt.Sprintf("/js/chunk-%02d.js", i))
+	}
+
+	tests := []struct {
+		name  string
+		keys  []string
+		root  string
+		paths []string
+	}{
+		{"no keys", nil, "", []string{}},
+		{"keys", []string{"js/app.js", "css/site.css"}, "", []string{"/css/site.css", "/js/app.js"}},
+		{"root index", []string{"index.html"}, "", []string{"/", "/index.html"}},
+		{"directory index", []string{"docs/index.html"}, "", []string{"/docs", "/docs/", "/docs/index.html"}},
+		{"root stripped", []string{"v2/app.js", "v2/index.html"}, "/v2/", []string{"/", "/app.js", "/index.html"}},
+		{"outside root", []string{"v1/app.js", "v2/app.js"}, "v2", []string{"/app.js"}},
+		{"escaped", []string{"img/summer photo.jpg", "docs/ü.html"}, "", []string{"/docs/%C3%BC.html", "/img/summer%20photo.jpg"}},
+		{"too many", many, "", []string{cfront.InvalidateAll}},
+		{"limit", many[:cfront.MaxInvalidationPaths], "", limit[:cfront.MaxInvalidationPaths]},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if paths := cfront.InvalidationPaths(tt.keys, tt.root); !reflect.DeepEqual(paths, tt.paths) {
+				t.Errorf("paths = %v, want %v", paths, tt.paths)
+			}
+		})
+	}
+}
@@ -0,0 +1,107 @@
+package cfront
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/cloudfront"
+	"github.com/aws/aws-sdk-go-v2/service/cloudfront/types"
+)
+
+// OriginAccessControlName is the name of the Origin Access Control Pilot
+// creates for a bucket. It is used to find and reuse it on later releases.
+func OriginAccessControlName(bucket string) string {
+	return fmt.Sprintf("pilot-oac-%v", bucket)
+}
+
+func CreateOriginAccessControl(
+	c context.Context,
+	api CloudfrontAPI,
+	input *cloudfront.CreateOriginAccessControlInput,
+) (*cloudfront.CreateOriginAccessControlOutput, error) {
+	return api.CreateOriginAccessControl(c, input)
+}
+
+func GetOriginAccessControl(
+	c context.Context,
+	api CloudfrontAPI,
+	input *cloudfront.GetOriginAccessControlInput,
+) (*cloudfront.GetOriginAccessControlOutput, error) {
+	return api.GetOriginAccessControl(c, input)
+}
+
+func GetAllOriginAccessControls(
+	c context.Context,
+	api CloudfrontAPI,
+	input *cloudfront.ListOriginAccessControlsInput,
+) (*cloudfront.ListOriginAccessControlsOutput, error) {
+	return api.ListOriginAccessControls(c, input)
+}
+
+func DeleteOriginAccessControl(
+	c context.Context,
+	api CloudfrontAPI,
+	input *cloudfront.DeleteOriginAccessControlInput,
+) (*cloudfront.DeleteOriginAccessControlOutput, error) {
+	return api.DeleteOriginAccessControl(c, input)
+}
+
+// EnsureOriginAccessControl returns the ID of the bucket's Origin Access
+// Control, creating it if it does not exist yet.
+func EnsureOriginAccessControl(c context.Context, api CloudfrontAPI, bucket string) (string, error) {
+	name := OriginAccessControlName(bucket)
+	input := &cloudfront.ListOriginAccessControlsInput{}
+
+	for {
+		list, err := GetAllOriginAccessControls(c, api, input)
+		if err != nil {
+			return "", err
+		}
+
+		for _, oac := range list.OriginAccessControlList.Items {
+			if aws.ToString(oac.Name) == name {
+				return *oac.Id, nil
+			}
+		}
+
+		if !aws.ToBool(list.OriginAccessControlList.IsTruncated) {
+			break
+		}
+
+		input.Marker = list.OriginAccessControlList.NextMarker
+	}
+
+	created, err := CreateOriginAccessControl(c, api, &cloudfront.CreateOriginAccessControlInput{
+		OriginAccessControlConfig: &types.OriginAccessControlConfig{
+			Name:                          &name,
+			Description:                   aws.String("This origin access control was created via Pilot"),
+			OriginAccessControlOriginType: types.OriginAccessControlOriginTypesS3,
+			SigningBehavior:               types.OriginAccessControlSigningBehaviorsAlways,
+			SigningProtocol:               types.OriginAccessControlSigningProtocolsSigv4,
+		},
+	})
+	if err != nil {
+		return "", err
+	}
+
+	return *created.OriginAccessControl.Id, nil
+}
+
+// RemoveOriginAccessControl deletes an Origin Access Control. It fails while
+// a distribution still uses it.
+func RemoveOriginAccessControl(c context.Context, api CloudfrontAPI, id string) error {
+	oac, err := GetOriginAccessControl(c, api, &cloudfront.GetOriginAccessControlInput{
+		Id: &id,
+	})
+	if err != nil {
+		return err
+	}
+
+	_, err = DeleteOriginAccessControl(c, api, &cloudfront.DeleteOriginAccessControlInput{
+		Id:      &id,
+		IfMatch: oac.ETag,
+	})
+
+	return err
+}
@@ -0,0 +1,127 @@
+package cfront
+
+import (
+	"sort"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/cloudfront/types"
+)
+
+// ReconcileDistributionConfig updates the fields of current that Pilot manages
+// to the values in desired and returns the names of the fields that changed.
+// Everything else, like origins or behaviors added by hand, is left alone.
+// current must come from GetDistributionConfig, desired from FormatDistributionInput.
+func ReconcileDistributionConfig(current *types.DistributionConfig, desired *types.DistributionConfig) []string {
+	changes := []string{}
+
+	want := desired.Origins.Items[0]
+	idx := -1
+	for i, o := range current.Origins.Items {
+		if aws.ToString(o.Id) == aws.ToString(want.Id) {
+			idx = i
+			break
+		}
+	}
+
+	if idx < 0 {
+		current.Origins.Items = append(current.Origins.Items, want)
+		current.Origins.Quantity = aws.Int32(int32(len(current.Origins.Items)))
+		changes = append(changes, "origin")
+	} else if !originEqual(current.Origins.Items[idx], want) {
+		current.Origins.Items[idx] = want
+		changes = append(changes, "origin")
+	}
+
+	behavior := current.DefaultCacheBehavior
+	if aws.ToString(behavior.TargetOriginId) != aws.ToString(desired.DefaultCacheBehavior.TargetOriginId) ||
+		aws.ToString(behavior.CachePolicyId) != aws.ToString(desired.DefaultCacheBehavior.CachePolicyId) {
+		behavior.TargetOriginId = desired.DefaultCacheBehavior.TargetOriginId
+		behavior.CachePolicyId = desired.DefaultCacheBehavior.CachePolicyId
+		changes = append(changes, "default cache behavior")
+	}
+
+	if aws.ToString(current.DefaultRootObject) != aws.ToString(desired.DefaultRootObject) {
+		current.DefaultRootObject = aws.String(aws.ToString(desired.DefaultRootObject))
+		changes = append(changes, "default root object")
+	}
+
+	if !sameItems(aliasItems(current.Aliases), aliasItems(desired.Aliases)) {
+		items := aliasItems(desired.Aliases)
+		current.Aliases = &types.Aliases{
+			Quantity: aws.Int32(int32(len(items))),
+			Items:    items,
+		}
+		changes = append(changes, "aliases")
+	}
+
+	wantCert := desired.ViewerCertificate
+	if wantCert == nil {
+		wantCert = &types.ViewerCertificate{CloudFrontDefaultCertificate: aws.Bool(true)}
+	}
+
+	if !certificateEqual(current.ViewerCertificate, wantCert) {
+		current.ViewerCertificate = wantCert
+		changes = append(changes, "viewer certificate")
+	}
+
+	if !aws.ToBool(current.Enabled) {
+		current.Enabled = aws.Bool(true)
+		changes = append(changes, "enabled")
+	}
+
+	if !aws.ToBool(current.IsIPV6Enabled) {
+		current.IsIPV6Enabled = aws.Bool(true)
+		changes = append(changes, "ipv6")
+	}
+
+	return changes
+}
+
+func originEqual(a types.Origin, b types.Origin) bool {
+	return aws.ToString(a.DomainName) == aws.ToString(b.DomainName) &&
+		aws.ToString(a.OriginPath) == aws.ToString(b.OriginPath) &&
+		aws.ToString(a.OriginAccessControlId) == aws.ToString(b.OriginAccessControlId) &&
+		(a.S3OriginConfig == nil) == (b.S3OriginConfig == nil) &&
+		(a.CustomOriginConfig == nil) == (b.CustomOriginConfig == nil)
+}
+
+func certificateEqual(a *types.ViewerCertificate, b *types.ViewerCertificate) bool {
+	if a == nil {
+		return aws.ToBool(b.CloudFrontDefaultCertificate)
+	}
+
+	if aws.ToBool(b.CloudFrontDefaultCertificate) {
+		return aws.ToBool(a.CloudFrontDefaultCertificate)
+	}
+
+	return aws.ToString(a.ACMCertificateArn) == aws.ToString(b.ACMCertificateArn) &&
+		a.SSLSupportMethod == b.SSLSupportMethod &&
+		a.MinimumProtocolVersion == b.MinimumProtocolVersion
+}
+
+func aliasItems(aliases *types.Aliases) []string {
+	if aliases == nil {
+		return []string{}
+	}
+
+	return aliases.Items
+}
+
+func sameItems(a []string, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+
+	x := append([]string{}, a...)
+	y := append([]string{}, b...)
+	sort.Strings(x)
+	sort.Strings(y)
+
+	for i := range x {
+		if x[i] != y[i] {
+			return false
+		}
+	}
+
+	return true
+}
@@ -0,0 +1,140 @@
+package cfront_test
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/cloudfront/types"
+	"github.com/pilot-framework/aws-cloudfront-waypoint-plugin/cfront"
+)
+
+func distributionConfig(opts cfront.DistributionOptions) *types.DistributionConfig {
+	return cfront.FormatDistributionInput(opts).DistributionConfigWithTags.DistributionConfig
+}
+
+func TestReconcileDistributionConfig(t *testing.T) {
+	base := cfront.DistributionOptions{Bucket: "site", Region: "us-east-1"}
+
+	withAliases := base
+	withAliases.Aliases = []string{"www.example.com", "example.com"}
+	withAliases.CertificateArn = "arn:aws:acm:us-east-1:123456789012:certificate/1"
+
+	tests := []struct {
+		name    string
+		current cfront.DistributionOptions
+		// edit changes the current configuration like a change made by hand
+		edit    func(c *types.DistributionConfig)
+		desired cfront.DistributionOptions
+		changes []string
+	}{
+		{
+			name:    "up to date",
+			current: base,
+			desired: base,
+			changes: []string{},
+		},
+		{
+			name:    "aliases in another order",
+			current: withAliases,
+			edit: func(c *types.DistributionConfig) {
+				c.Aliases.Items = []string{"example.com", "www.example.com"}
+			},
+			desired: withAliases,
+			changes: []string{},
+		},
+		{
+			name:    "origin added by hand is kept",
+			current: base,
+			edit: func(c *types.DistributionConfig) {
+				c.Origins.Items = append(c.Origins.Items, types.Origin{Id: aws.String("api"), DomainName: aws.String("api.example.com")})
+				c.Origins.Quantity = aws.Int32(2)
+			},
+			desired: base,
+			changes: []string{},
+		},
+		{
+			name:    "root",
+			current: base,
+			desired: cfront.DistributionOptions{Bucket: "site", Region: "us-east-1", Root: "/v2"},
+			changes: []string{"origin"},
+		},
+		{
+			// the REST endpoint does not serve index.html by itself
+			name:    "origin access control",
+			current: base,
+			desired: cfront.DistributionOptions{Bucket: "site", Region: "us-east-1", OriginAccessControlId: "OAC1"},
+			changes: []string{"origin", "default root object"},
+		},
+		{
+			name:    "missing origin",
+			current: base,
+			edit: func(c *types.DistributionConfig) {
+				c.Origins.Items[0].Id = aws.String("renamed")
+			},
+			desired: base,
+			changes: []string{"origin"},
+		},
+		{
+			name:    "cache behavior and root object",
+			current: base,
+			edit: func(c *types.DistributionConfig) {
+				c.DefaultCacheBehavior.CachePolicyId = aws.String("custom")
+				c.DefaultRootObject = aws.String("home.html")
+			},
+			desired: base,
+			changes: []string{"default cache behavior", "default root object"},
+		},
+		{
+			name:    "aliases and certificate added",
+			current: base,
+			desired: withAliases,
+			changes: []string{"aliases", "viewer certificate"},
+		},
+		{
+			name:    "aliases removed",
+			current: withAliases,
+			desired: base,
+			changes: []string{"aliases", "viewer certificate"},
+		},
+		{
+			name:    "disabled",
+			current: base,
+			edit: func(c *types.DistributionConfig) {
+				c.Enabled = aws.Bool(false)
+			},
+			desired: base,
+			changes: []string{"enabled"},
+		},
+		{
+			// the alias AAAA records resolve only with IPv6 enabled
+			name:    "ipv6 disabled",
+			current: base,
+			edit: func(c *types.DistributionConfig) {
+				c.IsIPV6Enabled = nil
+			},
+			desired: base,
+			changes: []string{"ipv6"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			current := distributionConfig(tt.current)
+			if tt.edit != nil {
+				tt.edit(current)
+			}
+			desired := distributionConfig(tt.desired)
+
+			changes := cfront.ReconcileDistributionConfig(current, desired)
+			if !reflect.DeepEqual(changes, tt.changes) {
+				t.Fatalf("changes = %v, want %v", changes, tt.changes)
+			}
+
+			// reconciling again finds nothing left to change
+			if again := cfront.ReconcileDistributionConfig(current, desired); len(again) != 0 {
+				t.Errorf("changes after reconciling = %v", again)
+			}
+		})
+	}
+}
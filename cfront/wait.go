@@ -0,0 +1,102 @@
+package cfront
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"time"
+)
+
+// Delays between polls of a Waiter created by NewWaiter
+const (
+	DefaultMinDelay = 5 * time.Second
+	DefaultMaxDelay = 30 * time.Second
+)
+
+// ErrWaitTimeout is returned when a resource did not reach the wanted state in time
+var ErrWaitTimeout = errors.New("timed out")
+
+// PollFunc reports the current status of a resource and whether it reached
+// the wanted state
+type PollFunc func(c context.Context) (status string, done bool, err error)
+
+// Waiter polls until a resource reaches the wanted state. The delay between
+// polls starts at MinDelay and doubles up to MaxDelay, with jitter so that
+// concurrent waits spread out.
+type Waiter struct {
+	MinDelay time.Duration
+	MaxDelay time.Duration
+	// MaxWait is how long to wait in total, zero polls once
+	MaxWait time.Duration
+	// OnStatus is called with the first status and whenever it changes
+	OnStatus func(status string)
+}
+
+func NewWaiter(maxWait time.Duration, onStatus func(status string)) *Waiter {
+	return &Waiter{
+		MinDelay: DefaultMinDelay,
+		MaxDelay: DefaultMaxDelay,
+		MaxWait:  maxWait,
+		OnStatus: onStatus,
+	}
+}
+
+// Wait calls poll until it is done, the context is canceled or MaxWait has
+// passed, in which case the error wraps ErrWaitTimeout. The last poll happens
+// at the deadline.
+func (w *Waiter) Wait(c context.Context, poll PollFunc) error {
+	deadline := time.Now().Add(w.MaxWait)
+	delay := w.MinDelay
+	last := ""
+
+	for i := 0; ; i++ {
+		status, done, err := poll(c)
+		if err != nil {
+			return err
+		}
+
+		if w.OnStatus != nil && (i == 0 || status != last) {
+			w.OnStatus(status)
+		}
+		last = status
+
+		if done {
+			return nil
+		}
+
+		remaining := time.Until(deadline)
+		if remaining <= 0 {
+			return fmt.Errorf("%w after %v, last status: %v", ErrWaitTimeout, w.MaxWait, status)
+		}
+
+		sleep := jitter(delay)
+		if sleep > remaining {
+			sleep = remaining
+		}
+
+		timer := time.NewTimer(sleep)
+		select {
+		case <-c.Done():
+			timer.Stop()
+			return c.Err()
+		case <-timer.C:
+		}
+
+		delay *= 2
+		if delay > w.MaxDelay {
+			delay = w.MaxDelay
+		}
+	}
+}
+
+// jitter returns a random duration between half of d and d
+func jitter(d time.Duration) time.Duration {
+	if d <= 1 {
+		return d
+	}
+
+	half := d / 2
+
+	return half + time.Duration(rand.Int63n(int64(d-half)))
+}
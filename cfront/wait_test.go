@@ -0,0 +1,171 @@
+package cfront
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// polls returns a PollFunc reporting the statuses in turn, done at the last
+// one unless it is empty, and the number of calls so far.
+func polls(statuses ...string) (PollFunc, *int) {
+	calls := 0
+
+	return func(c context.Context) (string, bool, error) {
+		i := calls
+		if i >= len(statuses) {
+			i = len(statuses) - 1
+		}
+		calls++
+
+		return statuses[i], i == len(statuses)-1 && statuses[i] != "", nil
+	}, &calls
+}
+
+func TestWaiterWait(t *testing.T) {
+	injected := errors.New("injected failure")
+
+	tests := []struct {
+		name     string
+		maxWait  time.Duration
+		poll     PollFunc
+		calls    int
+		reported []string
+		err      error
+	}{
+		{
+			name:     "done at once",
+			maxWait:  time.Second,
+			poll:     func(c context.Context) (string, bool, error) { return "Deployed", true, nil },
+			calls:    1,
+			reported: []string{"Deployed"},
+		},
+		{
+			name:     "zero wait polls once",
+			maxWait:  0,
+			poll:     func(c context.Context) (string, bool, error) { return "InProgress", false, nil },
+			calls:    1,
+			reported: []string{"InProgress"},
+			err:      ErrWaitTimeout,
+		},
+		{
+			name:     "status changes are reported once",
+			maxWait:  time.Second,
+			calls:    4,
+			reported: []string{"InProgress", "Deployed"},
+		},
+		{
+			name:    "poll error",
+			maxWait: time.Second,
+			poll:    func(c context.Context) (string, bool, error) { return "", false, injected },
+			calls:   1,
+			err:     injected,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			poll := tt.poll
+			if poll == nil {
+				poll, _ = polls("InProgress", "InProgress", "InProgress", "Deployed")
+			}
+
+			count := 0
+			reported := []string{}
+			w := &Waiter{
+				MinDelay: time.Millisecond,
+				MaxDelay: 2 * time.Millisecond,
+				MaxWait:  tt.maxWait,
+				OnStatus: func(status string) { reported = append(reported, status) },
+			}
+
+			err := w.Wait(context.Background(), func(c context.Context) (string, bool, error) {
+				count++
+				return poll(c)
+			})
+
+			if !errors.Is(err, tt.err) || (tt.err == nil && err != nil) {
+				t.Errorf("err = %v, want %v", err, tt.err)
+			}
+			if count != tt.calls {
+				t.Errorf("polled %d times, want %d", count, tt.calls)
+			}
+			if tt.reported != nil && !reflect.DeepEqual(reported, tt.reported) {
+				t.Errorf("reported %v, want %v", reported, tt.reported)
+			}
+		})
+	}
+}
+
+func TestWaiterTimeout(t *testing.T) {
+	poll, calls := polls("InProgress", "")
+	w := &Waiter{MinDelay: time.Millisecond, MaxDelay: 5 * time.Millisecond, MaxWait: 30 * time.Millisecond}
+
+	start := time.Now()
+	err := w.Wait(context.Background(), poll)
+
+	if !errors.Is(err, ErrWaitTimeout) {
+		t.Fatalf("err = %v, want ErrWaitTimeout", err)
+	}
+	if elapsed := time.Since(start); elapsed < w.MaxWait {
+		t.Errorf("gave up after %v, before MaxWait", elapsed)
+	}
+	if *calls < 2 {
+		t.Errorf("polled %d times before timing out", *calls)
+	}
+}
+
+func TestWaiterMaxDelay(t *testing.T) {
+	// without the cap the delays would double to over a second
+	poll, calls := polls("1", "2", "3", "4", "5", "6", "7", "8", "9", "10", "11", "12")
+	w := &Waiter{MinDelay: time.Millisecond, MaxDelay: 2 * time.Millisecond, MaxWait: time.Minute}
+
+	start := time.Now()
+	if err := w.Wait(context.Background(), poll); err != nil {
+		t.Fatal(err)
+	}
+
+	if elapsed := time.Since(start); elapsed > 500*time.Millisecond {
+		t.Errorf("%d polls took %v, the delay is not capped at %v", *calls, elapsed, w.MaxDelay)
+	}
+}
+
+func TestWaiterCanceled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	poll, calls := polls("InProgress", "")
+	w := &Waiter{MinDelay: time.Hour, MaxDelay: time.Hour, MaxWait: time.Hour}
+
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		cancel()
+	}()
+
+	if err := w.Wait(ctx, poll); !errors.Is(err, context.Canceled) {
+		t.Errorf("err = %v, want context.Canceled", err)
+	}
+	if *calls != 1 {
+		t.Errorf("polled %d times, want 1", *calls)
+	}
+}
+
+func TestJitter(t *testing.T) {
+	for _, d := range []time.Duration{0, 1, 2, time.Millisecond, DefaultMaxDelay} {
+		for i := 0; i < 100; i++ {
+			got := jitter(d)
+			if got < d/2 || got > d {
+				t.Fatalf("jitter(%v) = %v, want between %v and %v", d, got, d/2, d)
+			}
+		}
+	}
+
+	// the delays of concurrent waits spread out
+	seen := map[time.Duration]bool{}
+	for i := 0; i < 100; i++ {
+		seen[jitter(DefaultMaxDelay)] = true
+	}
+	if len(seen) < 2 {
+		t.Error("jitter returned the same delay every time")
+	}
+}
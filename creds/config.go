@@ -0,0 +1,86 @@
+package creds
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/credentials/stscreds"
+	"github.com/aws/aws-sdk-go-v2/service/sts"
+)
+
+// DefaultSessionName identifies the sessions of assumed roles in CloudTrail
+const DefaultSessionName = "pilot-waypoint"
+
+// stsRegion is used for STS when no region is configured, CloudFront is global
+const stsRegion = "us-east-1"
+
+// Options select the credentials of the AWS clients. Without a role the
+// default credential chain of the profile is used.
+type Options struct {
+	Region      string
+	Profile     string
+	RoleArn     string
+	ExternalId  string
+	SessionName string
+}
+
+// Validate checks the options set from the plugin configuration.
+func (o Options) Validate() error {
+	if o.RoleArn == "" && (o.ExternalId != "" || o.SessionName != "") {
+		return fmt.Errorf("external_id and session_name require role_arn")
+	}
+
+	if o.RoleArn != "" && (!strings.HasPrefix(o.RoleArn, "arn:") || !strings.Contains(o.RoleArn, ":role/")) {
+		return fmt.Errorf("role_arn must be the ARN of an IAM role, got: %v", o.RoleArn)
+	}
+
+	return nil
+}
+
+// LoadConfig loads the AWS configuration for the options. With a role, the
+// credentials of the profile assume it and the returned configuration holds
+// the cached role credentials, so that every client built from it shares them.
+func LoadConfig(c context.Context, o Options) (aws.Config, error) {
+	optFns := []func(*config.LoadOptions) error{}
+
+	if o.Region != "" {
+		optFns = append(optFns, config.WithRegion(o.Region))
+	}
+
+	if o.Profile != "" {
+		optFns = append(optFns, config.WithSharedConfigProfile(o.Profile))
+	}
+
+	cfg, err := config.LoadDefaultConfig(c, optFns...)
+	if err != nil {
+		return cfg, err
+	}
+
+	if o.RoleArn == "" {
+		return cfg, nil
+	}
+
+	stsClient := sts.NewFromConfig(cfg, func(so *sts.Options) {
+		if so.Region == "" {
+			so.Region = stsRegion
+		}
+	})
+
+	provider := stscreds.NewAssumeRoleProvider(stsClient, o.RoleArn, func(ao *stscreds.AssumeRoleOptions) {
+		ao.RoleSessionName = DefaultSessionName
+		if o.SessionName != "" {
+			ao.RoleSessionName = o.SessionName
+		}
+
+		if o.ExternalId != "" {
+			ao.ExternalID = aws.String(o.ExternalId)
+		}
+	})
+
+	cfg.Credentials = aws.NewCredentialsCache(provider)
+
+	return cfg, nil
+}
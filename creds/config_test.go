@@ -0,0 +1,81 @@
+package creds_test
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/credentials/stscreds"
+	"github.com/pilot-framework/aws-cloudfront-waypoint-plugin/creds"
+)
+
+func TestOptionsValidate(t *testing.T) {
+	roleArn := "arn:aws:iam::123456789012:role/deployer"
+
+	tests := []struct {
+		name    string
+		options creds.Options
+		err     bool
+	}{
+		{"defaults", creds.Options{}, false},
+		{"profile", creds.Options{Profile: "staging"}, false},
+		{"role", creds.Options{RoleArn: roleArn, ExternalId: "pilot", SessionName: "deploy"}, false},
+		{"role with a path", creds.Options{RoleArn: "arn:aws:iam::123456789012:role/ci/deployer"}, false},
+		{"external id without role", creds.Options{ExternalId: "pilot"}, true},
+		{"session name without role", creds.Options{SessionName: "deploy"}, true},
+		{"role name", creds.Options{RoleArn: "deployer"}, true},
+		{"user", creds.Options{RoleArn: "arn:aws:iam::123456789012:user/alice"}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.options.Validate(); (err != nil) != tt.err {
+				t.Errorf("err = %v, want error %v", err, tt.err)
+			}
+		})
+	}
+}
+
+func TestLoadConfig(t *testing.T) {
+	// static credentials of the environment, without the files of the machine
+	dir := t.TempDir()
+	t.Setenv("AWS_CONFIG_FILE", filepath.Join(dir, "config"))
+	t.Setenv("AWS_SHARED_CREDENTIALS_FILE", filepath.Join(dir, "credentials"))
+	t.Setenv("AWS_ACCESS_KEY_ID", "AKID")
+	t.Setenv("AWS_SECRET_ACCESS_KEY", "SECRET")
+
+	tests := []struct {
+		name    string
+		options creds.Options
+		assumed bool
+	}{
+		{"default chain", creds.Options{Region: "eu-west-1"}, false},
+		{"role", creds.Options{Region: "eu-west-1", RoleArn: "arn:aws:iam::123456789012:role/deployer"}, true},
+		// STS falls back to us-east-1
+		{"role without region", creds.Options{RoleArn: "arn:aws:iam::123456789012:role/deployer", ExternalId: "pilot"}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg, err := creds.LoadConfig(context.Background(), tt.options)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if cfg.Region != tt.options.Region {
+				t.Errorf("region = %q, want %q", cfg.Region, tt.options.Region)
+			}
+
+			// the clients share the cached role credentials
+			cache, ok := cfg.Credentials.(*aws.CredentialsCache)
+			if !ok {
+				t.Fatalf("credentials = %T, want a credentials cache", cfg.Credentials)
+			}
+
+			if assumed := cache.IsCredentialsProvider(&stscreds.AssumeRoleProvider{}); assumed != tt.assumed {
+				t.Errorf("assumes the role %v, want %v", assumed, tt.assumed)
+			}
+		})
+	}
+}
@@ -0,0 +1,164 @@
+// Package creds validates the AWS credentials the plugin runs with.
+package creds
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/iam"
+	"github.com/aws/aws-sdk-go-v2/service/iam/types"
+	"github.com/aws/aws-sdk-go-v2/service/sts"
+	"github.com/hashicorp/waypoint-plugin-sdk/terminal"
+)
+
+// Defines interface for needed STS functions
+type STSAPI interface {
+	GetCallerIdentity(
+		ctx context.Context,
+		input *sts.GetCallerIdentityInput,
+		optFns ...func(*sts.Options),
+	) (*sts.GetCallerIdentityOutput, error)
+}
+
+// Defines interface for needed IAM functions
+type IAMAPI interface {
+	SimulatePrincipalPolicy(
+		ctx context.Context,
+		input *iam.SimulatePrincipalPolicyInput,
+		optFns ...func(*iam.Options),
+	) (*iam.SimulatePrincipalPolicyOutput, error)
+	GetRole(
+		ctx context.Context,
+		input *iam.GetRoleInput,
+		optFns ...func(*iam.Options),
+	) (*iam.GetRoleOutput, error)
+}
+
+func GetCallerIdentity(
+	c context.Context,
+	api STSAPI,
+	input *sts.GetCallerIdentityInput,
+) (*sts.GetCallerIdentityOutput, error) {
+	return api.GetCallerIdentity(c, input)
+}
+
+func SimulatePrincipalPolicy(
+	c context.Context,
+	api IAMAPI,
+	input *iam.SimulatePrincipalPolicyInput,
+) (*iam.SimulatePrincipalPolicyOutput, error) {
+	return api.SimulatePrincipalPolicy(c, input)
+}
+
+func GetRole(
+	c context.Context,
+	api IAMAPI,
+	input *iam.GetRoleInput,
+) (*iam.GetRoleOutput, error) {
+	return api.GetRole(c, input)
+}
+
+// PrincipalArn returns the IAM ARN policies can be simulated for. Credentials
+// of an assumed role identify the session, which maps to the role itself.
+// The session ARN leaves out the path of the role, so the role is looked up.
+func PrincipalArn(c context.Context, api IAMAPI, callerArn string) (string, error) {
+	parts := strings.SplitN(callerArn, ":", 6)
+	if len(parts) != 6 || parts[2] != "sts" || !strings.HasPrefix(parts[5], "assumed-role/") {
+		return callerArn, nil
+	}
+
+	role := strings.Split(strings.TrimPrefix(parts[5], "assumed-role/"), "/")[0]
+
+	out, err := GetRole(c, api, &iam.GetRoleInput{RoleName: aws.String(role)})
+	if err != nil {
+		return "", err
+	}
+
+	return aws.ToString(out.Role.Arn), nil
+}
+
+// DeniedActions simulates the policies of the principal and returns the
+// actions it is not allowed to perform on any resource.
+func DeniedActions(c context.Context, api IAMAPI, principalArn string, actions []string) ([]string, error) {
+	denied := []string{}
+	input := &iam.SimulatePrincipalPolicyInput{
+		PolicySourceArn: &principalArn,
+		ActionNames:     actions,
+	}
+
+	for {
+		out, err := SimulatePrincipalPolicy(c, api, input)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, result := range out.EvaluationResults {
+			if result.EvalDecision != types.PolicyEvaluationDecisionTypeAllowed {
+				denied = append(denied, aws.ToString(result.EvalActionName))
+			}
+		}
+
+		if !out.IsTruncated {
+			break
+		}
+
+		input.Marker = out.Marker
+	}
+
+	sort.Strings(denied)
+
+	return denied, nil
+}
+
+// Guidance explains how to provide credentials with the given permissions.
+func Guidance(actions []string) string {
+	return fmt.Sprintf(`Provide AWS credentials to the Waypoint runner in one of these ways:
+  - set AWS_ACCESS_KEY_ID and AWS_SECRET_ACCESS_KEY (and AWS_SESSION_TOKEN for temporary credentials)
+  - set AWS_PROFILE to a profile in ~/.aws/credentials or ~/.aws/config, run "aws sso login" for SSO profiles
+  - run the runner on an EC2 instance, ECS task or EKS pod with an IAM role
+The credentials need to allow these actions:
+  %v`, strings.Join(actions, "\n  "))
+}
+
+// Validate checks that the credentials are valid and allow the actions.
+// When the policies cannot be simulated, e.g. because iam:GetRole or
+// iam:SimulatePrincipalPolicy is not allowed, missing permissions only surface
+// once they are used.
+func Validate(c context.Context, ui terminal.UI, stsApi STSAPI, iamApi IAMAPI, actions []string) error {
+	u := ui.Status()
+	defer u.Close()
+
+	u.Update("Validating AWS credentials...")
+
+	identity, err := GetCallerIdentity(c, stsApi, &sts.GetCallerIdentityInput{})
+	if err != nil {
+		u.Step(terminal.StatusError, "AWS credentials are missing or invalid: "+err.Error())
+		return err
+	}
+
+	callerArn := aws.ToString(identity.Arn)
+
+	principalArn, err := PrincipalArn(c, iamApi, callerArn)
+	if err != nil {
+		u.Step(terminal.StatusWarn, "Authenticated as "+callerArn+", the role could not be looked up: "+err.Error())
+		return nil
+	}
+
+	denied, err := DeniedActions(c, iamApi, principalArn, actions)
+	if err != nil {
+		u.Step(terminal.StatusWarn, "Authenticated as "+callerArn+", permissions could not be verified: "+err.Error())
+		return nil
+	}
+
+	if len(denied) > 0 {
+		u.Step(terminal.StatusError, fmt.Sprintf("%v is not allowed to perform: %v", callerArn, strings.Join(denied, ", ")))
+		return fmt.Errorf("missing permissions for %v", strings.Join(denied, ", "))
+	}
+
+	u.Step(terminal.StatusOK, "Authenticated as "+callerArn)
+
+	return nil
+}
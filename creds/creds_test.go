@@ -0,0 +1,192 @@
+package creds_test
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/iam"
+	"github.com/aws/aws-sdk-go-v2/service/iam/types"
+	"github.com/aws/aws-sdk-go-v2/service/sts"
+	"github.com/hashicorp/waypoint-plugin-sdk/terminal"
+	"github.com/pilot-framework/aws-cloudfront-waypoint-plugin/creds"
+)
+
+// fakeSTS returns the caller ARN or err
+type fakeSTS struct {
+	arn string
+	err error
+}
+
+func (f *fakeSTS) GetCallerIdentity(ctx context.Context, input *sts.GetCallerIdentityInput, optFns ...func(*sts.Options)) (*sts.GetCallerIdentityOutput, error) {
+	if f.err != nil {
+		return nil, f.err
+	}
+
+	return &sts.GetCallerIdentityOutput{Arn: aws.String(f.arn)}, nil
+}
+
+// fakeIAM allows the actions in allowed, one action per simulation page. Roles
+// maps role names to their ARN.
+type fakeIAM struct {
+	roles     map[string]string
+	allowed   map[string]bool
+	simulated []string
+	err       error
+}
+
+func (f *fakeIAM) GetRole(ctx context.Context, input *iam.GetRoleInput, optFns ...func(*iam.Options)) (*iam.GetRoleOutput, error) {
+	arn, ok := f.roles[aws.ToString(input.RoleName)]
+	if !ok {
+		return nil, errors.New("NoSuchEntity: role not found")
+	}
+
+	return &iam.GetRoleOutput{Role: &types.Role{Arn: aws.String(arn), RoleName: input.RoleName}}, nil
+}
+
+func (f *fakeIAM) SimulatePrincipalPolicy(ctx context.Context, input *iam.SimulatePrincipalPolicyInput, optFns ...func(*iam.Options)) (*iam.SimulatePrincipalPolicyOutput, error) {
+	if f.err != nil {
+		return nil, f.err
+	}
+
+	f.simulated = append(f.simulated, aws.ToString(input.PolicySourceArn))
+
+	i := 0
+	if input.Marker != nil {
+		for i < len(input.ActionNames) && input.ActionNames[i] != *input.Marker {
+			i++
+		}
+	}
+
+	action := input.ActionNames[i]
+	decision := types.PolicyEvaluationDecisionTypeImplicitDeny
+	if f.allowed[action] {
+		decision = types.PolicyEvaluationDecisionTypeAllowed
+	}
+
+	out := &iam.SimulatePrincipalPolicyOutput{
+		EvaluationResults: []types.EvaluationResult{{EvalActionName: aws.String(action), EvalDecision: decision}},
+	}
+
+	if i+1 < len(input.ActionNames) {
+		out.IsTruncated = true
+		out.Marker = aws.String(input.ActionNames[i+1])
+	}
+
+	return out, nil
+}
+
+func TestPrincipalArn(t *testing.T) {
+	api := &fakeIAM{roles: map[string]string{
+		"deployer": "arn:aws:iam::123456789012:role/ci/deployer",
+	}}
+
+	tests := []struct {
+		caller string
+		want   string
+		err    bool
+	}{
+		{"arn:aws:iam::123456789012:user/alice", "arn:aws:iam::123456789012:user/alice", false},
+		{"arn:aws:sts::123456789012:assumed-role/deployer/pilot-waypoint", "arn:aws:iam::123456789012:role/ci/deployer", false},
+		{"arn:aws:sts::123456789012:assumed-role/missing/pilot-waypoint", "", true},
+		{"arn:aws:sts::123456789012:federated-user/bob", "arn:aws:sts::123456789012:federated-user/bob", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.caller, func(t *testing.T) {
+			got, err := creds.PrincipalArn(context.Background(), api, tt.caller)
+			if (err != nil) != tt.err {
+				t.Fatalf("err = %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("PrincipalArn = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDeniedActions(t *testing.T) {
+	api := &fakeIAM{allowed: map[string]bool{"s3:GetObject": true}}
+
+	denied, err := creds.DeniedActions(context.Background(), api, "arn:aws:iam::123456789012:user/alice", []string{"s3:PutObject", "s3:GetObject", "s3:DeleteObject"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if want := []string{"s3:DeleteObject", "s3:PutObject"}; !reflect.DeepEqual(denied, want) {
+		t.Errorf("denied = %v, want %v", denied, want)
+	}
+	if len(api.simulated) != 3 {
+		t.Errorf("simulated %d pages, want 3", len(api.simulated))
+	}
+}
+
+func TestValidate(t *testing.T) {
+	roleArn := "arn:aws:iam::123456789012:role/ci/deployer"
+	sessionArn := "arn:aws:sts::123456789012:assumed-role/deployer/pilot-waypoint"
+
+	tests := []struct {
+		name string
+		sts  *fakeSTS
+		iam  *fakeIAM
+		err  bool
+	}{
+		{
+			name: "allowed",
+			sts:  &fakeSTS{arn: sessionArn},
+			iam:  &fakeIAM{roles: map[string]string{"deployer": roleArn}, allowed: map[string]bool{"s3:GetObject": true}},
+		},
+		{
+			name: "denied",
+			sts:  &fakeSTS{arn: sessionArn},
+			iam:  &fakeIAM{roles: map[string]string{"deployer": roleArn}},
+			err:  true,
+		},
+		{
+			name: "invalid credentials",
+			sts:  &fakeSTS{err: errors.New("InvalidClientTokenId")},
+			iam:  &fakeIAM{},
+			err:  true,
+		},
+		{
+			// missing permissions only surface once they are used
+			name: "role not readable",
+			sts:  &fakeSTS{arn: sessionArn},
+			iam:  &fakeIAM{},
+		},
+		{
+			name: "simulation not allowed",
+			sts:  &fakeSTS{arn: "arn:aws:iam::123456789012:user/alice"},
+			iam:  &fakeIAM{err: errors.New("AccessDenied")},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+
+			err := creds.Validate(ctx, terminal.ConsoleUI(ctx), tt.sts, tt.iam, []string{"s3:GetObject"})
+			if (err != nil) != tt.err {
+				t.Fatalf("err = %v", err)
+			}
+
+			// policies are simulated for the role, not the session
+			for _, arn := range tt.iam.simulated {
+				if strings.Contains(arn, "assumed-role") {
+					t.Errorf("simulated %v", arn)
+				}
+			}
+		})
+	}
+}
+
+func TestGuidance(t *testing.T) {
+	guidance := creds.Guidance([]string{"s3:GetObject", "s3:PutObject"})
+
+	if !strings.Contains(guidance, "  s3:GetObject\n  s3:PutObject") {
+		t.Errorf("guidance does not list the actions:\n%v", guidance)
+	}
+}
@@ -0,0 +1,121 @@
+package fakes
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"sync"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/acm"
+	"github.com/aws/aws-sdk-go-v2/service/acm/types"
+	"github.com/pilot-framework/aws-cloudfront-waypoint-plugin/cfront"
+)
+
+var _ cfront.CertificateAPI = (*ACM)(nil)
+
+// ACM is an in-memory ACM implementing cfront.CertificateAPI. It is safe
+// for concurrent use.
+type ACM struct {
+	// PageSize is how many certificates a list call returns at most, zero
+	// uses the API default
+	PageSize int
+
+	mu           sync.Mutex
+	certificates []types.CertificateSummary
+}
+
+func NewACM() *ACM {
+	return &ACM{}
+}
+
+// AddCertificate adds an RSA 2048 certificate for domain and the
+// alternative names with the given status and returns its ARN.
+func (f *ACM) AddCertificate(status types.CertificateStatus, domain string, alternativeNames ...string) string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	arn := fmt.Sprintf("arn:aws:acm:%v:123456789012:certificate/%d", cfront.CertificateRegion, len(f.certificates)+1)
+
+	f.certificates = append(f.certificates, types.CertificateSummary{
+		CertificateArn:                  aws.String(arn),
+		DomainName:                      aws.String(domain),
+		SubjectAlternativeNameSummaries: append([]string{domain}, alternativeNames...),
+		Status:                          status,
+		KeyAlgorithm:                    types.KeyAlgorithmRsa2048,
+	})
+
+	return arn
+}
+
+func (f *ACM) ListCertificates(ctx context.Context, input *acm.ListCertificatesInput, optFns ...func(*acm.Options)) (*acm.ListCertificatesOutput, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	start := 0
+	if input.NextToken != nil {
+		n, err := strconv.Atoi(*input.NextToken)
+		if err != nil {
+			return nil, apiError("InvalidNextTokenException", "invalid next token %v", *input.NextToken)
+		}
+		start = n
+	}
+
+	max := f.PageSize
+	if max <= 0 {
+		max = 1000
+	}
+	if input.MaxItems != nil && *input.MaxItems > 0 && int(*input.MaxItems) < max {
+		max = int(*input.MaxItems)
+	}
+
+	out := &acm.ListCertificatesOutput{}
+
+	for i := start; i < len(f.certificates); i++ {
+		if len(out.CertificateSummaryList) == max {
+			out.NextToken = aws.String(strconv.Itoa(i))
+			break
+		}
+
+		cert := f.certificates[i]
+		if !hasStatus(input.CertificateStatuses, cert.Status) {
+			continue
+		}
+		// without a key type filter only RSA 2048 certificates are listed
+		if input.Includes == nil || len(input.Includes.KeyTypes) == 0 {
+			if cert.KeyAlgorithm != types.KeyAlgorithmRsa2048 {
+				continue
+			}
+		} else if !hasKeyType(input.Includes.KeyTypes, cert.KeyAlgorithm) {
+			continue
+		}
+
+		out.CertificateSummaryList = append(out.CertificateSummaryList, cert)
+	}
+
+	return out, nil
+}
+
+func hasStatus(statuses []types.CertificateStatus, status types.CertificateStatus) bool {
+	if len(statuses) == 0 {
+		return true
+	}
+
+	for _, s := range statuses {
+		if s == status {
+			return true
+		}
+	}
+
+	return false
+}
+
+func hasKeyType(keyTypes []types.KeyAlgorithm, keyType types.KeyAlgorithm) bool {
+	for _, k := range keyTypes {
+		if k == keyType {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,817 @@
+package fakes
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/cloudfront"
+	"github.com/aws/aws-sdk-go-v2/service/cloudfront/types"
+	"github.com/pilot-framework/aws-cloudfront-waypoint-plugin/cfront"
+)
+
+var _ cfront.CloudfrontAPI = (*CloudFront)(nil)
+
+// Distribution is a fake distribution. Every change to its configuration
+// gets a new ETag and is InProgress until it has been polled DeployPolls times.
+type Distribution struct {
+	Id         string
+	ARN        string
+	DomainName string
+	ETag       string
+	Status     string
+	Config     *types.DistributionConfig
+	Tags       map[string]string
+
+	Invalidations map[string]*types.Invalidation
+
+	pending      int
+	invalidating map[string]*int
+}
+
+// CloudFront is an in-memory CloudFront implementing cfront.CloudfrontAPI.
+// It is safe for concurrent use.
+type CloudFront struct {
+	// DeployPolls is how many times a distribution or invalidation is polled
+	// before a change is deployed, zero deploys changes at once
+	DeployPolls int
+
+	mu            sync.Mutex
+	distributions map[string]*Distribution
+	oacs          map[string]*types.OriginAccessControl
+	oacETags      map[string]string
+	policies      map[string]*types.ContinuousDeploymentPolicy
+	policyETags   map[string]string
+	seq           int
+}
+
+func NewCloudFront() *CloudFront {
+	return &CloudFront{
+		distributions: map[string]*Distribution{},
+		oacs:          map[string]*types.OriginAccessControl{},
+		oacETags:      map[string]string{},
+		policies:      map[string]*types.ContinuousDeploymentPolicy{},
+		policyETags:   map[string]string{},
+	}
+}
+
+// Distribution returns the distribution with the given ID or nil. The
+// returned distribution must not be used while calls are in flight.
+func (f *CloudFront) Distribution(id string) *Distribution {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	return f.distributions[id]
+}
+
+// DistributionIds returns the sorted IDs of all distributions.
+func (f *CloudFront) DistributionIds() []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	return f.sortedIds()
+}
+
+// OriginAccessControlIds returns the sorted IDs of all Origin Access Controls.
+func (f *CloudFront) OriginAccessControlIds() []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	ids := []string{}
+	for id := range f.oacs {
+		ids = append(ids, id)
+	}
+
+	sort.Strings(ids)
+
+	return ids
+}
+
+// ContinuousDeploymentPolicyIds returns the sorted IDs of all continuous deployment policies.
+func (f *CloudFront) ContinuousDeploymentPolicyIds() []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	ids := []string{}
+	for id := range f.policies {
+		ids = append(ids, id)
+	}
+
+	sort.Strings(ids)
+
+	return ids
+}
+
+// sortedIds must be called with the lock held
+func (f *CloudFront) sortedIds() []string {
+	ids := []string{}
+	for id := range f.distributions {
+		ids = append(ids, id)
+	}
+
+	sort.Strings(ids)
+
+	return ids
+}
+
+// nextId must be called with the lock held
+func (f *CloudFront) nextId(prefix string) string {
+	f.seq++
+	return fmt.Sprintf("%v%012d", prefix, f.seq)
+}
+
+// changed must be called with the lock held
+func (f *CloudFront) changed(d *Distribution) {
+	d.ETag = f.nextId("ETAG")
+	d.Status = "InProgress"
+	d.pending = f.DeployPolls
+
+	if d.pending == 0 {
+		d.Status = "Deployed"
+	}
+}
+
+// poll advances a change by one poll, it must be called with the lock held
+func poll(pending *int, status *string, done string) {
+	if *pending > 0 {
+		*pending--
+	}
+
+	if *pending == 0 {
+		*status = done
+	}
+}
+
+// copyConfig returns a deep copy so that callers cannot change the stored
+// configuration without an update, like with a real distribution
+func copyConfig(config *types.DistributionConfig) *types.DistributionConfig {
+	data, err := json.Marshal(config)
+	if err != nil {
+		panic(err)
+	}
+
+	copied := &types.DistributionConfig{}
+	if err := json.Unmarshal(data, copied); err != nil {
+		panic(err)
+	}
+
+	return copied
+}
+
+// distribution must be called with the lock held
+func (f *CloudFront) distribution(id *string) (*Distribution, error) {
+	d, ok := f.distributions[aws.ToString(id)]
+	if !ok {
+		return nil, apiError("NoSuchDistribution", "the distribution %v does not exist", aws.ToString(id))
+	}
+
+	return d, nil
+}
+
+func (d *Distribution) output() *types.Distribution {
+	return &types.Distribution{
+		Id:                 aws.String(d.Id),
+		ARN:                aws.String(d.ARN),
+		DomainName:         aws.String(d.DomainName),
+		Status:             aws.String(d.Status),
+		DistributionConfig: copyConfig(d.Config),
+		LastModifiedTime:   aws.Time(time.Now()),
+	}
+}
+
+func (f *CloudFront) CreateDistributionWithTags(ctx context.Context, input *cloudfront.CreateDistributionWithTagsInput, optFns ...func(*cloudfront.Options)) (*cloudfront.CreateDistributionWithTagsOutput, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if input.DistributionConfigWithTags == nil || input.DistributionConfigWithTags.DistributionConfig == nil {
+		return nil, apiError("InvalidArgument", "the distribution configuration is missing")
+	}
+
+	config := input.DistributionConfigWithTags.DistributionConfig
+	for _, o := range config.Origins.Items {
+		if id := aws.ToString(o.OriginAccessControlId); id != "" {
+			if _, ok := f.oacs[id]; !ok {
+				return nil, apiError("NoSuchOriginAccessControl", "the origin access control %v does not exist", id)
+			}
+		}
+	}
+
+	id := f.nextId("E")
+	d := &Distribution{
+		Id:            id,
+		ARN:           "arn:aws:cloudfront::123456789012:distribution/" + id,
+		DomainName:    strings.ToLower(id) + ".cloudfront.net",
+		Config:        copyConfig(config),
+		Tags:          map[string]string{},
+		Invalidations: map[string]*types.Invalidation{},
+		invalidating:  map[string]*int{},
+	}
+
+	if tags := input.DistributionConfigWithTags.Tags; tags != nil {
+		for _, tag := range tags.Items {
+			d.Tags[aws.ToString(tag.Key)] = aws.ToString(tag.Value)
+		}
+	}
+
+	f.changed(d)
+	f.distributions[id] = d
+
+	return &cloudfront.CreateDistributionWithTagsOutput{
+		Distribution: d.output(),
+		ETag:         aws.String(d.ETag),
+	}, nil
+}
+
+// GetDistribution advances the status of a pending change by one poll.
+func (f *CloudFront) GetDistribution(ctx context.Context, input *cloudfront.GetDistributionInput, optFns ...func(*cloudfront.Options)) (*cloudfront.GetDistributionOutput, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	d, err := f.distribution(input.Id)
+	if err != nil {
+		return nil, err
+	}
+
+	poll(&d.pending, &d.Status, "Deployed")
+
+	return &cloudfront.GetDistributionOutput{
+		Distribution: d.output(),
+		ETag:         aws.String(d.ETag),
+	}, nil
+}
+
+func (f *CloudFront) GetDistributionConfig(ctx context.Context, input *cloudfront.GetDistributionConfigInput, optFns ...func(*cloudfront.Options)) (*cloudfront.GetDistributionConfigOutput, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	d, err := f.distribution(input.Id)
+	if err != nil {
+		return nil, err
+	}
+
+	return &cloudfront.GetDistributionConfigOutput{
+		DistributionConfig: copyConfig(d.Config),
+		ETag:               aws.String(d.ETag),
+	}, nil
+}
+
+func (f *CloudFront) UpdateDistribution(ctx context.Context, input *cloudfront.UpdateDistributionInput, optFns ...func(*cloudfront.Options)) (*cloudfront.UpdateDistributionOutput, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	d, err := f.distribution(input.Id)
+	if err != nil {
+		return nil, err
+	}
+
+	if aws.ToString(input.IfMatch) != d.ETag {
+		return nil, apiError("PreconditionFailed", "the ETag %v does not match %v", aws.ToString(input.IfMatch), d.ETag)
+	}
+
+	if aws.ToString(input.DistributionConfig.CallerReference) != aws.ToString(d.Config.CallerReference) {
+		return nil, apiError("IllegalUpdate", "the caller reference cannot be changed")
+	}
+
+	if id := aws.ToString(input.DistributionConfig.ContinuousDeploymentPolicyId); id != "" {
+		if _, ok := f.policies[id]; !ok {
+			return nil, apiError("NoSuchContinuousDeploymentPolicy", "the continuous deployment policy %v does not exist", id)
+		}
+	}
+
+	d.Config = copyConfig(input.DistributionConfig)
+	f.changed(d)
+
+	return &cloudfront.UpdateDistributionOutput{
+		Distribution: d.output(),
+		ETag:         aws.String(d.ETag),
+	}, nil
+}
+
+// DeleteDistribution only deletes disabled distributions whose changes are deployed.
+func (f *CloudFront) DeleteDistribution(ctx context.Context, input *cloudfront.DeleteDistributionInput, optFns ...func(*cloudfront.Options)) (*cloudfront.DeleteDistributionOutput, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	d, err := f.distribution(input.Id)
+	if err != nil {
+		return nil, err
+	}
+
+	if aws.ToString(input.IfMatch) != d.ETag {
+		return nil, apiError("PreconditionFailed", "the ETag %v does not match %v", aws.ToString(input.IfMatch), d.ETag)
+	}
+
+	if aws.ToBool(d.Config.Enabled) || d.Status != "Deployed" {
+		return nil, apiError("DistributionNotDisabled", "the distribution %v is not disabled and deployed", d.Id)
+	}
+
+	if aws.ToString(d.Config.ContinuousDeploymentPolicyId) != "" {
+		return nil, apiError("IllegalDelete", "the distribution %v has a continuous deployment policy", d.Id)
+	}
+
+	if id := f.policyFor(d.DomainName); id != "" {
+		return nil, apiError("StagingDistributionInUse", "the staging distribution %v is used by %v", d.Id, id)
+	}
+
+	delete(f.distributions, d.Id)
+
+	return &cloudfront.DeleteDistributionOutput{}, nil
+}
+
+// ListDistributions pages through the distributions ordered by ID, the
+// marker is the last ID of the previous page.
+func (f *CloudFront) ListDistributions(ctx context.Context, input *cloudfront.ListDistributionsInput, optFns ...func(*cloudfront.Options)) (*cloudfront.ListDistributionsOutput, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	max := int(aws.ToInt32(input.MaxItems))
+	if max <= 0 || max > 100 {
+		max = 100
+	}
+
+	list := &types.DistributionList{
+		Marker:      input.Marker,
+		MaxItems:    aws.Int32(int32(max)),
+		IsTruncated: aws.Bool(false),
+	}
+
+	for _, id := range f.sortedIds() {
+		if id <= aws.ToString(input.Marker) {
+			continue
+		}
+
+		if len(list.Items) == max {
+			list.IsTruncated = aws.Bool(true)
+			list.NextMarker = list.Items[max-1].Id
+			break
+		}
+
+		d := f.distributions[id]
+		config := copyConfig(d.Config)
+		list.Items = append(list.Items, types.DistributionSummary{
+			Id:                   aws.String(d.Id),
+			ARN:                  aws.String(d.ARN),
+			DomainName:           aws.String(d.DomainName),
+			Status:               aws.String(d.Status),
+			Enabled:              config.Enabled,
+			Comment:              config.Comment,
+			Aliases:              config.Aliases,
+			Origins:              config.Origins,
+			DefaultCacheBehavior: config.DefaultCacheBehavior,
+			ViewerCertificate:    config.ViewerCertificate,
+			PriceClass:           config.PriceClass,
+			Staging:              aws.Bool(aws.ToBool(config.Staging)),
+		})
+	}
+
+	list.Quantity = aws.Int32(int32(len(list.Items)))
+
+	return &cloudfront.ListDistributionsOutput{DistributionList: list}, nil
+}
+
+// distributionByArn must be called with the lock held
+func (f *CloudFront) distributionByArn(arn *string) (*Distribution, error) {
+	for _, d := range f.distributions {
+		if d.ARN == aws.ToString(arn) {
+			return d, nil
+		}
+	}
+
+	return nil, apiError("NoSuchResource", "the resource %v does not exist", aws.ToString(arn))
+}
+
+func (f *CloudFront) ListTagsForResource(ctx context.Context, input *cloudfront.ListTagsForResourceInput, optFns ...func(*cloudfront.Options)) (*cloudfront.ListTagsForResourceOutput, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	d, err := f.distributionByArn(input.Resource)
+	if err != nil {
+		return nil, err
+	}
+
+	keys := []string{}
+	for key := range d.Tags {
+		keys = append(keys, key)
+	}
+
+	sort.Strings(keys)
+
+	tags := &types.Tags{}
+	for _, key := range keys {
+		tags.Items = append(tags.Items, types.Tag{Key: aws.String(key), Value: aws.String(d.Tags[key])})
+	}
+
+	return &cloudfront.ListTagsForResourceOutput{Tags: tags}, nil
+}
+
+func (f *CloudFront) TagResource(ctx context.Context, input *cloudfront.TagResourceInput, optFns ...func(*cloudfront.Options)) (*cloudfront.TagResourceOutput, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	d, err := f.distributionByArn(input.Resource)
+	if err != nil {
+		return nil, err
+	}
+
+	if input.Tags != nil {
+		for _, tag := range input.Tags.Items {
+			d.Tags[aws.ToString(tag.Key)] = aws.ToString(tag.Value)
+		}
+	}
+
+	return &cloudfront.TagResourceOutput{}, nil
+}
+
+func (f *CloudFront) CreateOriginRequestPolicy(ctx context.Context, input *cloudfront.CreateOriginRequestPolicyInput, optFns ...func(*cloudfront.Options)) (*cloudfront.CreateOriginRequestPolicyOutput, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	return &cloudfront.CreateOriginRequestPolicyOutput{
+		ETag: aws.String(f.nextId("ETAG")),
+		OriginRequestPolicy: &types.OriginRequestPolicy{
+			Id:                        aws.String(f.nextId("ORP")),
+			OriginRequestPolicyConfig: input.OriginRequestPolicyConfig,
+		},
+	}, nil
+}
+
+func (f *CloudFront) DeleteOriginRequestPolicy(ctx context.Context, input *cloudfront.DeleteOriginRequestPolicyInput, optFns ...func(*cloudfront.Options)) (*cloudfront.DeleteOriginRequestPolicyOutput, error) {
+	return &cloudfront.DeleteOriginRequestPolicyOutput{}, nil
+}
+
+func (f *CloudFront) CreateInvalidation(ctx context.Context, input *cloudfront.CreateInvalidationInput, optFns ...func(*cloudfront.Options)) (*cloudfront.CreateInvalidationOutput, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	d, err := f.distribution(input.DistributionId)
+	if err != nil {
+		return nil, err
+	}
+
+	batch := input.InvalidationBatch
+	if batch == nil || batch.Paths == nil || int(aws.ToInt32(batch.Paths.Quantity)) != len(batch.Paths.Items) {
+		return nil, apiError("InconsistentQuantities", "the quantity does not match the number of paths")
+	}
+
+	inv := &types.Invalidation{
+		Id:                aws.String(f.nextId("I")),
+		CreateTime:        aws.Time(time.Now()),
+		InvalidationBatch: batch,
+		Status:            aws.String("InProgress"),
+	}
+
+	pending := f.DeployPolls
+	if pending == 0 {
+		inv.Status = aws.String("Completed")
+	}
+
+	d.Invalidations[*inv.Id] = inv
+	d.invalidating[*inv.Id] = &pending
+
+	return &cloudfront.CreateInvalidationOutput{Invalidation: inv}, nil
+}
+
+// GetInvalidation completes an invalidation after it has been polled DeployPolls times.
+func (f *CloudFront) GetInvalidation(ctx context.Context, input *cloudfront.GetInvalidationInput, optFns ...func(*cloudfront.Options)) (*cloudfront.GetInvalidationOutput, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	d, err := f.distribution(input.DistributionId)
+	if err != nil {
+		return nil, err
+	}
+
+	inv, ok := d.Invalidations[aws.ToString(input.Id)]
+	if !ok {
+		return nil, apiError("NoSuchInvalidation", "the invalidation %v does not exist", aws.ToString(input.Id))
+	}
+
+	status := aws.ToString(inv.Status)
+	poll(d.invalidating[*inv.Id], &status, "Completed")
+	inv.Status = aws.String(status)
+
+	out := *inv
+
+	return &cloudfront.GetInvalidationOutput{Invalidation: &out}, nil
+}
+
+func (f *CloudFront) CreateOriginAccessControl(ctx context.Context, input *cloudfront.CreateOriginAccessControlInput, optFns ...func(*cloudfront.Options)) (*cloudfront.CreateOriginAccessControlOutput, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	name := aws.ToString(input.OriginAccessControlConfig.Name)
+	for _, oac := range f.oacs {
+		if aws.ToString(oac.OriginAccessControlConfig.Name) == name {
+			return nil, apiError("OriginAccessControlAlreadyExists", "an origin access control named %v already exists", name)
+		}
+	}
+
+	oac := &types.OriginAccessControl{
+		Id:                        aws.String(f.nextId("O")),
+		OriginAccessControlConfig: input.OriginAccessControlConfig,
+	}
+	f.oacs[*oac.Id] = oac
+	f.oacETags[*oac.Id] = f.nextId("ETAG")
+
+	return &cloudfront.CreateOriginAccessControlOutput{
+		ETag:                aws.String(f.oacETags[*oac.Id]),
+		OriginAccessControl: oac,
+	}, nil
+}
+
+func (f *CloudFront) GetOriginAccessControl(ctx context.Context, input *cloudfront.GetOriginAccessControlInput, optFns ...func(*cloudfront.Options)) (*cloudfront.GetOriginAccessControlOutput, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	oac, ok := f.oacs[aws.ToString(input.Id)]
+	if !ok {
+		return nil, apiError("NoSuchOriginAccessControl", "the origin access control %v does not exist", aws.ToString(input.Id))
+	}
+
+	return &cloudfront.GetOriginAccessControlOutput{
+		ETag:                aws.String(f.oacETags[*oac.Id]),
+		OriginAccessControl: oac,
+	}, nil
+}
+
+func (f *CloudFront) ListOriginAccessControls(ctx context.Context, input *cloudfront.ListOriginAccessControlsInput, optFns ...func(*cloudfront.Options)) (*cloudfront.ListOriginAccessControlsOutput, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	ids := []string{}
+	for id := range f.oacs {
+		ids = append(ids, id)
+	}
+
+	sort.Strings(ids)
+
+	list := &types.OriginAccessControlList{
+		Marker:      input.Marker,
+		IsTruncated: aws.Bool(false),
+	}
+
+	for _, id := range ids {
+		oac := f.oacs[id]
+		list.Items = append(list.Items, types.OriginAccessControlSummary{
+			Id:                            oac.Id,
+			Name:                          oac.OriginAccessControlConfig.Name,
+			Description:                   oac.OriginAccessControlConfig.Description,
+			OriginAccessControlOriginType: oac.OriginAccessControlConfig.OriginAccessControlOriginType,
+			SigningBehavior:               oac.OriginAccessControlConfig.SigningBehavior,
+			SigningProtocol:               oac.OriginAccessControlConfig.SigningProtocol,
+		})
+	}
+
+	list.Quantity = aws.Int32(int32(len(list.Items)))
+
+	return &cloudfront.ListOriginAccessControlsOutput{OriginAccessControlList: list}, nil
+}
+
+// DeleteOriginAccessControl fails while a distribution still uses the Origin Access Control.
+func (f *CloudFront) DeleteOriginAccessControl(ctx context.Context, input *cloudfront.DeleteOriginAccessControlInput, optFns ...func(*cloudfront.Options)) (*cloudfront.DeleteOriginAccessControlOutput, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	id := aws.ToString(input.Id)
+	if _, ok := f.oacs[id]; !ok {
+		return nil, apiError("NoSuchOriginAccessControl", "the origin access control %v does not exist", id)
+	}
+
+	if aws.ToString(input.IfMatch) != f.oacETags[id] {
+		return nil, apiError("PreconditionFailed", "the ETag %v does not match %v", aws.ToString(input.IfMatch), f.oacETags[id])
+	}
+
+	for _, d := range f.distributions {
+		for _, o := range d.Config.Origins.Items {
+			if aws.ToString(o.OriginAccessControlId) == id {
+				return nil, apiError("OriginAccessControlInUse", "the origin access control %v is used by %v", id, d.Id)
+			}
+		}
+	}
+
+	delete(f.oacs, id)
+	delete(f.oacETags, id)
+
+	return &cloudfront.DeleteOriginAccessControlOutput{}, nil
+}
+
+// policyFor returns the ID of a policy routing to the staging domain, it
+// must be called with the lock held
+func (f *CloudFront) policyFor(domain string) string {
+	for id, p := range f.policies {
+		for _, name := range p.ContinuousDeploymentPolicyConfig.StagingDistributionDnsNames.Items {
+			if name == domain {
+				return id
+			}
+		}
+	}
+
+	return ""
+}
+
+// policy must be called with the lock held
+func (f *CloudFront) policy(id *string) (*types.ContinuousDeploymentPolicy, error) {
+	p, ok := f.policies[aws.ToString(id)]
+	if !ok {
+		return nil, apiError("NoSuchContinuousDeploymentPolicy", "the continuous deployment policy %v does not exist", aws.ToString(id))
+	}
+
+	return p, nil
+}
+
+// copyPolicy returns a deep copy of a stored policy
+func copyPolicy(p *types.ContinuousDeploymentPolicy) *types.ContinuousDeploymentPolicy {
+	data, err := json.Marshal(p)
+	if err != nil {
+		panic(err)
+	}
+
+	copied := &types.ContinuousDeploymentPolicy{}
+	if err := json.Unmarshal(data, copied); err != nil {
+		panic(err)
+	}
+
+	return copied
+}
+
+// CopyDistribution creates a staging distribution without the aliases of the primary.
+func (f *CloudFront) CopyDistribution(ctx context.Context, input *cloudfront.CopyDistributionInput, optFns ...func(*cloudfront.Options)) (*cloudfront.CopyDistributionOutput, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	primary, err := f.distribution(input.PrimaryDistributionId)
+	if err != nil {
+		return nil, err
+	}
+
+	if input.IfMatch != nil && aws.ToString(input.IfMatch) != primary.ETag {
+		return nil, apiError("PreconditionFailed", "the ETag %v does not match %v", aws.ToString(input.IfMatch), primary.ETag)
+	}
+
+	if aws.ToBool(primary.Config.Staging) {
+		return nil, apiError("InvalidArgument", "the distribution %v is a staging distribution", primary.Id)
+	}
+
+	config := copyConfig(primary.Config)
+	config.CallerReference = input.CallerReference
+	config.Staging = aws.Bool(aws.ToBool(input.Staging))
+	config.Aliases = nil
+	config.ContinuousDeploymentPolicyId = nil
+
+	id := f.nextId("E")
+	d := &Distribution{
+		Id:            id,
+		ARN:           "arn:aws:cloudfront::123456789012:distribution/" + id,
+		DomainName:    strings.ToLower(id) + ".cloudfront.net",
+		Config:        config,
+		Tags:          map[string]string{},
+		Invalidations: map[string]*types.Invalidation{},
+		invalidating:  map[string]*int{},
+	}
+
+	f.changed(d)
+	f.distributions[id] = d
+
+	return &cloudfront.CopyDistributionOutput{
+		Distribution: d.output(),
+		ETag:         aws.String(d.ETag),
+	}, nil
+}
+
+// UpdateDistributionWithStagingConfig copies the staging configuration to the
+// primary, keeping what identifies the primary like its aliases and policy.
+func (f *CloudFront) UpdateDistributionWithStagingConfig(ctx context.Context, input *cloudfront.UpdateDistributionWithStagingConfigInput, optFns ...func(*cloudfront.Options)) (*cloudfront.UpdateDistributionWithStagingConfigOutput, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	primary, err := f.distribution(input.Id)
+	if err != nil {
+		return nil, err
+	}
+
+	staging, err := f.distribution(input.StagingDistributionId)
+	if err != nil {
+		return nil, err
+	}
+
+	if want := primary.ETag + ", " + staging.ETag; aws.ToString(input.IfMatch) != want {
+		return nil, apiError("PreconditionFailed", "the ETags %v do not match %v", aws.ToString(input.IfMatch), want)
+	}
+
+	config := copyConfig(staging.Config)
+	config.CallerReference = primary.Config.CallerReference
+	config.Aliases = primary.Config.Aliases
+	config.ViewerCertificate = primary.Config.ViewerCertificate
+	config.ContinuousDeploymentPolicyId = primary.Config.ContinuousDeploymentPolicyId
+	config.Staging = aws.Bool(false)
+
+	primary.Config = config
+	f.changed(primary)
+
+	return &cloudfront.UpdateDistributionWithStagingConfigOutput{
+		Distribution: primary.output(),
+		ETag:         aws.String(primary.ETag),
+	}, nil
+}
+
+func (f *CloudFront) CreateContinuousDeploymentPolicy(ctx context.Context, input *cloudfront.CreateContinuousDeploymentPolicyInput, optFns ...func(*cloudfront.Options)) (*cloudfront.CreateContinuousDeploymentPolicyOutput, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	config := input.ContinuousDeploymentPolicyConfig
+	if config == nil || config.StagingDistributionDnsNames == nil || len(config.StagingDistributionDnsNames.Items) == 0 {
+		return nil, apiError("InvalidArgument", "the staging distribution DNS names are missing")
+	}
+
+	for _, name := range config.StagingDistributionDnsNames.Items {
+		if id := f.policyFor(name); id != "" {
+			return nil, apiError("StagingDistributionInUse", "the staging distribution %v is used by %v", name, id)
+		}
+	}
+
+	p := copyPolicy(&types.ContinuousDeploymentPolicy{
+		Id:                               aws.String(f.nextId("CDP")),
+		ContinuousDeploymentPolicyConfig: config,
+		LastModifiedTime:                 aws.Time(time.Now()),
+	})
+	f.policies[*p.Id] = p
+	f.policyETags[*p.Id] = f.nextId("ETAG")
+
+	return &cloudfront.CreateContinuousDeploymentPolicyOutput{
+		ContinuousDeploymentPolicy: copyPolicy(p),
+		ETag:                       aws.String(f.policyETags[*p.Id]),
+	}, nil
+}
+
+func (f *CloudFront) GetContinuousDeploymentPolicy(ctx context.Context, input *cloudfront.GetContinuousDeploymentPolicyInput, optFns ...func(*cloudfront.Options)) (*cloudfront.GetContinuousDeploymentPolicyOutput, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	p, err := f.policy(input.Id)
+	if err != nil {
+		return nil, err
+	}
+
+	return &cloudfront.GetContinuousDeploymentPolicyOutput{
+		ContinuousDeploymentPolicy: copyPolicy(p),
+		ETag:                       aws.String(f.policyETags[*p.Id]),
+	}, nil
+}
+
+func (f *CloudFront) UpdateContinuousDeploymentPolicy(ctx context.Context, input *cloudfront.UpdateContinuousDeploymentPolicyInput, optFns ...func(*cloudfront.Options)) (*cloudfront.UpdateContinuousDeploymentPolicyOutput, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	p, err := f.policy(input.Id)
+	if err != nil {
+		return nil, err
+	}
+
+	if aws.ToString(input.IfMatch) != f.policyETags[*p.Id] {
+		return nil, apiError("PreconditionFailed", "the ETag %v does not match %v", aws.ToString(input.IfMatch), f.policyETags[*p.Id])
+	}
+
+	updated := copyPolicy(&types.ContinuousDeploymentPolicy{
+		Id:                               p.Id,
+		ContinuousDeploymentPolicyConfig: input.ContinuousDeploymentPolicyConfig,
+		LastModifiedTime:                 aws.Time(time.Now()),
+	})
+	f.policies[*p.Id] = updated
+	f.policyETags[*p.Id] = f.nextId("ETAG")
+
+	return &cloudfront.UpdateContinuousDeploymentPolicyOutput{
+		ContinuousDeploymentPolicy: copyPolicy(updated),
+		ETag:                       aws.String(f.policyETags[*p.Id]),
+	}, nil
+}
+
+// DeleteContinuousDeploymentPolicy fails while a primary distribution uses the policy.
+func (f *CloudFront) DeleteContinuousDeploymentPolicy(ctx context.Context, input *cloudfront.DeleteContinuousDeploymentPolicyInput, optFns ...func(*cloudfront.Options)) (*cloudfront.DeleteContinuousDeploymentPolicyOutput, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	p, err := f.policy(input.Id)
+	if err != nil {
+		return nil, err
+	}
+
+	if aws.ToString(input.IfMatch) != f.policyETags[*p.Id] {
+		return nil, apiError("PreconditionFailed", "the ETag %v does not match %v", aws.ToString(input.IfMatch), f.policyETags[*p.Id])
+	}
+
+	for _, d := range f.distributions {
+		if aws.ToString(d.Config.ContinuousDeploymentPolicyId) == *p.Id {
+			return nil, apiError("ContinuousDeploymentPolicyInUse", "the continuous deployment policy %v is used by %v", *p.Id, d.Id)
+		}
+	}
+
+	delete(f.policies, *p.Id)
+	delete(f.policyETags, *p.Id)
+
+	return &cloudfront.DeleteContinuousDeploymentPolicyOutput{}, nil
+}
@@ -0,0 +1,125 @@
+package fakes
+
+import (
+	"context"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/route53"
+	"github.com/aws/aws-sdk-go-v2/service/route53/types"
+	"github.com/pilot-framework/aws-cloudfront-waypoint-plugin/r53"
+)
+
+var _ r53.Route53API = (*Route53)(nil)
+
+// Route53 is an in-memory Route 53 implementing r53.Route53API. Hosted
+// zones have to be added with AddHostedZone. It is safe for concurrent use.
+type Route53 struct {
+	mu    sync.Mutex
+	zones map[string]map[string]types.ResourceRecordSet
+}
+
+func NewRoute53() *Route53 {
+	return &Route53{
+		zones: map[string]map[string]types.ResourceRecordSet{},
+	}
+}
+
+// AddHostedZone creates an empty hosted zone with the given ID.
+func (f *Route53) AddHostedZone(id string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.zones[id] = map[string]types.ResourceRecordSet{}
+}
+
+// Records returns the record sets of a hosted zone sorted by name and type.
+func (f *Route53) Records(zoneId string) []types.ResourceRecordSet {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	keys := []string{}
+	for key := range f.zones[zoneId] {
+		keys = append(keys, key)
+	}
+
+	sort.Strings(keys)
+
+	records := []types.ResourceRecordSet{}
+	for _, key := range keys {
+		records = append(records, f.zones[zoneId][key])
+	}
+
+	return records
+}
+
+// Route 53 names are case-insensitive and fully qualified
+func recordKey(set *types.ResourceRecordSet) string {
+	return strings.TrimSuffix(strings.ToLower(aws.ToString(set.Name)), ".") + " " + string(set.Type)
+}
+
+// aliasTarget is the normalized DNS name an alias record points at, empty
+// for other records
+func aliasTarget(set *types.ResourceRecordSet) string {
+	if set.AliasTarget == nil {
+		return ""
+	}
+
+	return strings.TrimSuffix(strings.ToLower(aws.ToString(set.AliasTarget.DNSName)), ".")
+}
+
+func (f *Route53) ChangeResourceRecordSets(ctx context.Context, input *route53.ChangeResourceRecordSetsInput, optFns ...func(*route53.Options)) (*route53.ChangeResourceRecordSetsOutput, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	zone, ok := f.zones[aws.ToString(input.HostedZoneId)]
+	if !ok {
+		return nil, apiError("NoSuchHostedZone", "no hosted zone found with ID: %v", aws.ToString(input.HostedZoneId))
+	}
+
+	if input.ChangeBatch == nil || len(input.ChangeBatch.Changes) == 0 {
+		return nil, apiError("InvalidInput", "the change batch must contain changes")
+	}
+
+	// a batch is applied as a whole or not at all
+	changed := map[string]types.ResourceRecordSet{}
+	for key, set := range zone {
+		changed[key] = set
+	}
+
+	for _, change := range input.ChangeBatch.Changes {
+		key := recordKey(change.ResourceRecordSet)
+		current, exists := changed[key]
+
+		switch change.Action {
+		case types.ChangeActionCreate:
+			if exists {
+				return nil, apiError("InvalidChangeBatch", "Tried to create resource record set [name='%v', type='%v'] but it already exists", aws.ToString(change.ResourceRecordSet.Name), change.ResourceRecordSet.Type)
+			}
+			changed[key] = *change.ResourceRecordSet
+		case types.ChangeActionUpsert:
+			changed[key] = *change.ResourceRecordSet
+		case types.ChangeActionDelete:
+			if !exists {
+				return nil, apiError("InvalidChangeBatch", "Tried to delete resource record set [name='%v', type='%v'] but it was not found", aws.ToString(change.ResourceRecordSet.Name), change.ResourceRecordSet.Type)
+			}
+			if aliasTarget(&current) != aliasTarget(change.ResourceRecordSet) {
+				return nil, apiError("InvalidChangeBatch", "Tried to delete resource record set [name='%v', type='%v'] but the values provided do not match the current values", aws.ToString(change.ResourceRecordSet.Name), change.ResourceRecordSet.Type)
+			}
+			delete(changed, key)
+		default:
+			return nil, apiError("InvalidInput", "unknown change action %v", change.Action)
+		}
+	}
+
+	f.zones[aws.ToString(input.HostedZoneId)] = changed
+
+	return &route53.ChangeResourceRecordSetsOutput{
+		ChangeInfo: &types.ChangeInfo{
+			Id:     aws.String("C" + aws.ToString(input.HostedZoneId)),
+			Status: types.ChangeStatusPending,
+		},
+	}, nil
+}
@@ -0,0 +1,765 @@
+// Package fakes provides stateful in-memory implementations of the AWS APIs
+// the plugin uses, so that deploys, releases and destroys can be run without
+// an AWS account.
+package fakes
+
+import (
+	"bytes"
+	"context"
+	"crypto/md5"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"github.com/aws/smithy-go"
+	"github.com/pilot-framework/aws-cloudfront-waypoint-plugin/platform"
+)
+
+var _ platform.S3BucketAPI = (*S3)(nil)
+
+// Object is an object stored in a fake bucket
+type Object struct {
+	Key             string
+	Body            []byte
+	ETag            string
+	ContentType     string
+	ContentEncoding string
+	CacheControl    string
+	Metadata        map[string]string
+	LastModified    time.Time
+}
+
+// Bucket is a fake bucket. Objects holds the current version of every key
+// that is not deleted. Until versioning is enabled every object has the
+// version "null", afterwards deletes leave delete markers behind.
+type Bucket struct {
+	Name              string
+	Region            string
+	Policy            string
+	PublicAccessBlock *types.PublicAccessBlockConfiguration
+	Website           *types.WebsiteConfiguration
+	Versioning        types.BucketVersioningStatus
+	Objects           map[string]*Object
+
+	// versions are the versions and delete markers of each key, oldest first
+	versions map[string][]*version
+	uploads  map[string]*multipartUpload
+}
+
+// version is an object version or a delete marker if object is nil
+type version struct {
+	id       string
+	seq      int
+	object   *Object
+	modified time.Time
+}
+
+type multipartUpload struct {
+	input *s3.CreateMultipartUploadInput
+	parts map[int32][]byte
+}
+
+// S3 is an in-memory S3 implementing platform.S3BucketAPI. It is safe for
+// concurrent use.
+type S3 struct {
+	mu      sync.Mutex
+	buckets map[string]*Bucket
+	seq     int
+}
+
+func NewS3() *S3 {
+	return &S3{
+		buckets: map[string]*Bucket{},
+	}
+}
+
+// Bucket returns the bucket with the given name or nil. The returned bucket
+// must not be used while calls are in flight.
+func (f *S3) Bucket(name string) *Bucket {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	return f.buckets[name]
+}
+
+// Keys returns the sorted keys of the objects in a bucket.
+func (f *S3) Keys(bucket string) []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	b, ok := f.buckets[bucket]
+	if !ok {
+		return nil
+	}
+
+	return sortedKeys(b.Objects, "")
+}
+
+func apiError(code string, format string, args ...interface{}) error {
+	return &smithy.GenericAPIError{Code: code, Message: fmt.Sprintf(format, args...)}
+}
+
+func etag(data []byte) string {
+	sum := md5.Sum(data)
+	return `"` + hex.EncodeToString(sum[:]) + `"`
+}
+
+func sortedKeys(objects map[string]*Object, prefix string) []string {
+	keys := []string{}
+	for key := range objects {
+		if strings.HasPrefix(key, prefix) {
+			keys = append(keys, key)
+		}
+	}
+
+	sort.Strings(keys)
+
+	return keys
+}
+
+// versionId returns the ID of a new version, it must be called with the lock held
+func (f *S3) versionId(b *Bucket, key string) (string, int) {
+	f.seq++
+
+	if b.Versioning != types.BucketVersioningStatusEnabled {
+		// the null version is replaced
+		f.dropVersion(b, key, "null")
+		return "null", f.seq
+	}
+
+	return fmt.Sprintf("version-%06d", f.seq), f.seq
+}
+
+// store adds obj as the current version of its key, it must be called with
+// the lock held
+func (f *S3) store(b *Bucket, obj *Object) string {
+	id, seq := f.versionId(b, obj.Key)
+	b.versions[obj.Key] = append(b.versions[obj.Key], &version{id: id, seq: seq, object: obj, modified: obj.LastModified})
+	b.Objects[obj.Key] = obj
+
+	return id
+}
+
+// remove deletes a version of the key or, without an ID, the key itself. It
+// must be called with the lock held.
+func (f *S3) remove(b *Bucket, key string, versionId string) {
+	switch {
+	case versionId != "":
+		f.dropVersion(b, key, versionId)
+	case b.Versioning == "":
+		delete(b.versions, key)
+		delete(b.Objects, key)
+	default:
+		id, seq := f.versionId(b, key)
+		b.versions[key] = append(b.versions[key], &version{id: id, seq: seq, modified: time.Now()})
+		delete(b.Objects, key)
+	}
+}
+
+// dropVersion removes a version and makes the latest remaining one current,
+// it must be called with the lock held
+func (f *S3) dropVersion(b *Bucket, key string, versionId string) {
+	kept := []*version{}
+	for _, v := range b.versions[key] {
+		if v.id != versionId {
+			kept = append(kept, v)
+		}
+	}
+
+	if len(kept) == 0 {
+		delete(b.versions, key)
+		delete(b.Objects, key)
+		return
+	}
+
+	b.versions[key] = kept
+
+	if latest := kept[len(kept)-1]; latest.object != nil {
+		b.Objects[key] = latest.object
+	} else {
+		delete(b.Objects, key)
+	}
+}
+
+// bucket must be called with the lock held
+func (f *S3) bucket(name *string) (*Bucket, error) {
+	b, ok := f.buckets[aws.ToString(name)]
+	if !ok {
+		return nil, apiError("NoSuchBucket", "the bucket %v does not exist", aws.ToString(name))
+	}
+
+	return b, nil
+}
+
+func (f *S3) CreateBucket(ctx context.Context, input *s3.CreateBucketInput, optFns ...func(*s3.Options)) (*s3.CreateBucketOutput, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	name := aws.ToString(input.Bucket)
+	if _, ok := f.buckets[name]; ok {
+		return nil, apiError("BucketAlreadyOwnedByYou", "the bucket %v already exists", name)
+	}
+
+	region := "us-east-1"
+	if input.CreateBucketConfiguration != nil {
+		region = string(input.CreateBucketConfiguration.LocationConstraint)
+	}
+
+	f.buckets[name] = &Bucket{
+		Name:     name,
+		Region:   region,
+		Objects:  map[string]*Object{},
+		versions: map[string][]*version{},
+		uploads:  map[string]*multipartUpload{},
+	}
+
+	return &s3.CreateBucketOutput{Location: aws.String("/" + name)}, nil
+}
+
+func (f *S3) PutBucketPolicy(ctx context.Context, input *s3.PutBucketPolicyInput, optFns ...func(*s3.Options)) (*s3.PutBucketPolicyOutput, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	b, err := f.bucket(input.Bucket)
+	if err != nil {
+		return nil, err
+	}
+
+	// a public policy is rejected while public policies are blocked
+	if b.PublicAccessBlock != nil && b.PublicAccessBlock.BlockPublicPolicy && strings.Contains(aws.ToString(input.Policy), `"Principal": "*"`) {
+		return nil, apiError("AccessDenied", "public policies are blocked for %v", b.Name)
+	}
+
+	b.Policy = aws.ToString(input.Policy)
+
+	return &s3.PutBucketPolicyOutput{}, nil
+}
+
+func (f *S3) PutPublicAccessBlock(ctx context.Context, input *s3.PutPublicAccessBlockInput, optFns ...func(*s3.Options)) (*s3.PutPublicAccessBlockOutput, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	b, err := f.bucket(input.Bucket)
+	if err != nil {
+		return nil, err
+	}
+
+	b.PublicAccessBlock = input.PublicAccessBlockConfiguration
+
+	return &s3.PutPublicAccessBlockOutput{}, nil
+}
+
+func (f *S3) PutBucketWebsite(ctx context.Context, input *s3.PutBucketWebsiteInput, optFns ...func(*s3.Options)) (*s3.PutBucketWebsiteOutput, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	b, err := f.bucket(input.Bucket)
+	if err != nil {
+		return nil, err
+	}
+
+	b.Website = input.WebsiteConfiguration
+
+	return &s3.PutBucketWebsiteOutput{}, nil
+}
+
+func (f *S3) GetBucketAcl(ctx context.Context, input *s3.GetBucketAclInput, optFns ...func(*s3.Options)) (*s3.GetBucketAclOutput, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	_, err := f.bucket(input.Bucket)
+	if err != nil {
+		return nil, err
+	}
+
+	return &s3.GetBucketAclOutput{
+		Owner: &types.Owner{ID: aws.String("fake-owner")},
+	}, nil
+}
+
+func (f *S3) GetBucketVersioning(ctx context.Context, input *s3.GetBucketVersioningInput, optFns ...func(*s3.Options)) (*s3.GetBucketVersioningOutput, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	b, err := f.bucket(input.Bucket)
+	if err != nil {
+		return nil, err
+	}
+
+	return &s3.GetBucketVersioningOutput{Status: b.Versioning}, nil
+}
+
+func (f *S3) PutBucketVersioning(ctx context.Context, input *s3.PutBucketVersioningInput, optFns ...func(*s3.Options)) (*s3.PutBucketVersioningOutput, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	b, err := f.bucket(input.Bucket)
+	if err != nil {
+		return nil, err
+	}
+
+	if input.VersioningConfiguration != nil {
+		b.Versioning = input.VersioningConfiguration.Status
+	}
+
+	return &s3.PutBucketVersioningOutput{}, nil
+}
+
+func (f *S3) DeleteBucket(ctx context.Context, input *s3.DeleteBucketInput, optFns ...func(*s3.Options)) (*s3.DeleteBucketOutput, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	b, err := f.bucket(input.Bucket)
+	if err != nil {
+		return nil, err
+	}
+
+	// versions and delete markers must be deleted as well
+	if len(b.versions) > 0 {
+		return nil, apiError("BucketNotEmpty", "the bucket %v is not empty", b.Name)
+	}
+
+	delete(f.buckets, b.Name)
+
+	return &s3.DeleteBucketOutput{}, nil
+}
+
+func (f *S3) PutObject(ctx context.Context, input *s3.PutObjectInput, optFns ...func(*s3.Options)) (*s3.PutObjectOutput, error) {
+	var data []byte
+	if input.Body != nil {
+		var err error
+		data, err = io.ReadAll(input.Body)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	b, err := f.bucket(input.Bucket)
+	if err != nil {
+		return nil, err
+	}
+
+	obj := &Object{
+		Key:             aws.ToString(input.Key),
+		Body:            data,
+		ETag:            etag(data),
+		ContentType:     aws.ToString(input.ContentType),
+		ContentEncoding: aws.ToString(input.ContentEncoding),
+		CacheControl:    aws.ToString(input.CacheControl),
+		Metadata:        input.Metadata,
+		LastModified:    time.Now(),
+	}
+	id := f.store(b, obj)
+
+	return &s3.PutObjectOutput{ETag: aws.String(obj.ETag), VersionId: aws.String(id)}, nil
+}
+
+func (f *S3) HeadObject(ctx context.Context, input *s3.HeadObjectInput, optFns ...func(*s3.Options)) (*s3.HeadObjectOutput, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	b, err := f.bucket(input.Bucket)
+	if err != nil {
+		return nil, err
+	}
+
+	obj, ok := b.Objects[aws.ToString(input.Key)]
+	if !ok {
+		return nil, apiError("NotFound", "the key %v does not exist", aws.ToString(input.Key))
+	}
+
+	return &s3.HeadObjectOutput{
+		ContentLength:   int64(len(obj.Body)),
+		ContentType:     aws.String(obj.ContentType),
+		ContentEncoding: aws.String(obj.ContentEncoding),
+		CacheControl:    aws.String(obj.CacheControl),
+		ETag:            aws.String(obj.ETag),
+		LastModified:    aws.Time(obj.LastModified),
+		Metadata:        obj.Metadata,
+	}, nil
+}
+
+func (f *S3) GetObject(ctx context.Context, input *s3.GetObjectInput, optFns ...func(*s3.Options)) (*s3.GetObjectOutput, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	b, err := f.bucket(input.Bucket)
+	if err != nil {
+		return nil, err
+	}
+
+	obj, ok := b.Objects[aws.ToString(input.Key)]
+	if !ok {
+		return nil, apiError("NoSuchKey", "the key %v does not exist", aws.ToString(input.Key))
+	}
+
+	return &s3.GetObjectOutput{
+		Body:            io.NopCloser(bytes.NewReader(obj.Body)),
+		ContentLength:   int64(len(obj.Body)),
+		ContentType:     aws.String(obj.ContentType),
+		ContentEncoding: aws.String(obj.ContentEncoding),
+		CacheControl:    aws.String(obj.CacheControl),
+		ETag:            aws.String(obj.ETag),
+		LastModified:    aws.Time(obj.LastModified),
+		Metadata:        obj.Metadata,
+	}, nil
+}
+
+// ListObjectsV2 pages through the keys in order, the continuation token is
+// the last key or common prefix of the previous page.
+func (f *S3) ListObjectsV2(ctx context.Context, input *s3.ListObjectsV2Input, optFns ...func(*s3.Options)) (*s3.ListObjectsV2Output, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	b, err := f.bucket(input.Bucket)
+	if err != nil {
+		return nil, err
+	}
+
+	max := int(input.MaxKeys)
+	if max <= 0 || max > 1000 {
+		max = 1000
+	}
+
+	after := aws.ToString(input.StartAfter)
+	if input.ContinuationToken != nil {
+		after = *input.ContinuationToken
+	}
+
+	out := &s3.ListObjectsV2Output{
+		Name:    input.Bucket,
+		Prefix:  input.Prefix,
+		MaxKeys: int32(max),
+	}
+
+	prefix := aws.ToString(input.Prefix)
+	delimiter := aws.ToString(input.Delimiter)
+	if delimiter != "" {
+		out.Delimiter = input.Delimiter
+	}
+
+	// keys are rolled up into common prefixes like S3 does, a common prefix
+	// counts as a single key and can be the continuation token
+	last := ""
+	count := 0
+
+	for _, key := range sortedKeys(b.Objects, prefix) {
+		if key <= after || (delimiter != "" && strings.HasSuffix(after, delimiter) && strings.HasPrefix(key, after)) {
+			continue
+		}
+
+		common := ""
+		if delimiter != "" {
+			if i := strings.Index(key[len(prefix):], delimiter); i >= 0 {
+				common = key[:len(prefix)+i+len(delimiter)]
+			}
+		}
+
+		if common != "" && common == last {
+			continue
+		}
+
+		if count == max {
+			out.IsTruncated = true
+			out.NextContinuationToken = aws.String(last)
+			break
+		}
+
+		count++
+
+		if common != "" {
+			out.CommonPrefixes = append(out.CommonPrefixes, types.CommonPrefix{Prefix: aws.String(common)})
+			last = common
+			continue
+		}
+
+		obj := b.Objects[key]
+		out.Contents = append(out.Contents, types.Object{
+			Key:          aws.String(key),
+			ETag:         aws.String(obj.ETag),
+			Size:         int64(len(obj.Body)),
+			LastModified: aws.Time(obj.LastModified),
+		})
+		last = key
+	}
+
+	out.KeyCount = int32(count)
+
+	return out, nil
+}
+
+// ListObjectVersions pages through the versions and delete markers by key,
+// the newest version of a key first. The markers are the key and version ID
+// of the last entry of the previous page.
+func (f *S3) ListObjectVersions(ctx context.Context, input *s3.ListObjectVersionsInput, optFns ...func(*s3.Options)) (*s3.ListObjectVersionsOutput, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	b, err := f.bucket(input.Bucket)
+	if err != nil {
+		return nil, err
+	}
+
+	max := int(input.MaxKeys)
+	if max <= 0 || max > 1000 {
+		max = 1000
+	}
+
+	out := &s3.ListObjectVersionsOutput{
+		Name:            input.Bucket,
+		Prefix:          input.Prefix,
+		KeyMarker:       input.KeyMarker,
+		VersionIdMarker: input.VersionIdMarker,
+		MaxKeys:         int32(max),
+	}
+
+	keys := []string{}
+	for key := range b.versions {
+		if strings.HasPrefix(key, aws.ToString(input.Prefix)) && key >= aws.ToString(input.KeyMarker) {
+			keys = append(keys, key)
+		}
+	}
+
+	sort.Strings(keys)
+
+	count := 0
+
+	for _, key := range keys {
+		versions := b.versions[key]
+
+		// resume after the marker, or after the whole key without a version.
+		// The marker may have been deleted since it was listed, the versions
+		// older than it are still left to list then.
+		start := len(versions) - 1
+		if key == aws.ToString(input.KeyMarker) {
+			marker := 0
+			fmt.Sscanf(aws.ToString(input.VersionIdMarker), "version-%d", &marker)
+
+			start = -1
+			for i, v := range versions {
+				if v.id == aws.ToString(input.VersionIdMarker) {
+					start = i - 1
+					break
+				}
+				if v.seq < marker {
+					start = i
+				}
+			}
+		}
+
+		for i := start; i >= 0; i-- {
+			v := versions[i]
+
+			if count == max {
+				out.IsTruncated = true
+				return out, nil
+			}
+
+			count++
+			out.NextKeyMarker = aws.String(key)
+			out.NextVersionIdMarker = aws.String(v.id)
+
+			latest := i == len(versions)-1
+
+			if v.object == nil {
+				out.DeleteMarkers = append(out.DeleteMarkers, types.DeleteMarkerEntry{
+					Key:          aws.String(key),
+					VersionId:    aws.String(v.id),
+					IsLatest:     latest,
+					LastModified: aws.Time(v.modified),
+				})
+				continue
+			}
+
+			out.Versions = append(out.Versions, types.ObjectVersion{
+				Key:          aws.String(key),
+				VersionId:    aws.String(v.id),
+				IsLatest:     latest,
+				ETag:         aws.String(v.object.ETag),
+				Size:         int64(len(v.object.Body)),
+				LastModified: aws.Time(v.modified),
+			})
+		}
+	}
+
+	// the markers are only set on truncated listings
+	out.NextKeyMarker = nil
+	out.NextVersionIdMarker = nil
+
+	return out, nil
+}
+
+func (f *S3) DeleteObject(ctx context.Context, input *s3.DeleteObjectInput, optFns ...func(*s3.Options)) (*s3.DeleteObjectOutput, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	b, err := f.bucket(input.Bucket)
+	if err != nil {
+		return nil, err
+	}
+
+	f.remove(b, aws.ToString(input.Key), aws.ToString(input.VersionId))
+
+	return &s3.DeleteObjectOutput{}, nil
+}
+
+func (f *S3) DeleteObjects(ctx context.Context, input *s3.DeleteObjectsInput, optFns ...func(*s3.Options)) (*s3.DeleteObjectsOutput, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	b, err := f.bucket(input.Bucket)
+	if err != nil {
+		return nil, err
+	}
+
+	if input.Delete == nil || len(input.Delete.Objects) > 1000 {
+		return nil, apiError("MalformedXML", "a request deletes between 1 and 1000 objects")
+	}
+
+	out := &s3.DeleteObjectsOutput{}
+	for _, id := range input.Delete.Objects {
+		f.remove(b, aws.ToString(id.Key), aws.ToString(id.VersionId))
+		out.Deleted = append(out.Deleted, types.DeletedObject{Key: id.Key, VersionId: id.VersionId})
+	}
+
+	return out, nil
+}
+
+func (f *S3) CreateMultipartUpload(ctx context.Context, input *s3.CreateMultipartUploadInput, optFns ...func(*s3.Options)) (*s3.CreateMultipartUploadOutput, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	b, err := f.bucket(input.Bucket)
+	if err != nil {
+		return nil, err
+	}
+
+	f.seq++
+	id := "upload-" + strconv.Itoa(f.seq)
+	b.uploads[id] = &multipartUpload{
+		input: input,
+		parts: map[int32][]byte{},
+	}
+
+	return &s3.CreateMultipartUploadOutput{
+		Bucket:   input.Bucket,
+		Key:      input.Key,
+		UploadId: aws.String(id),
+	}, nil
+}
+
+// upload must be called with the lock held
+func (f *S3) upload(bucket *string, id *string) (*Bucket, *multipartUpload, error) {
+	b, err := f.bucket(bucket)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	upload, ok := b.uploads[aws.ToString(id)]
+	if !ok {
+		return nil, nil, apiError("NoSuchUpload", "the upload %v does not exist", aws.ToString(id))
+	}
+
+	return b, upload, nil
+}
+
+func (f *S3) UploadPart(ctx context.Context, input *s3.UploadPartInput, optFns ...func(*s3.Options)) (*s3.UploadPartOutput, error) {
+	data, err := io.ReadAll(input.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	_, upload, err := f.upload(input.Bucket, input.UploadId)
+	if err != nil {
+		return nil, err
+	}
+
+	upload.parts[input.PartNumber] = data
+
+	return &s3.UploadPartOutput{ETag: aws.String(etag(data))}, nil
+}
+
+// CompleteMultipartUpload assembles the listed parts, the ETag is the MD5 of
+// the part MD5s followed by the number of parts like S3 computes it.
+func (f *S3) CompleteMultipartUpload(ctx context.Context, input *s3.CompleteMultipartUploadInput, optFns ...func(*s3.Options)) (*s3.CompleteMultipartUploadOutput, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	b, upload, err := f.upload(input.Bucket, input.UploadId)
+	if err != nil {
+		return nil, err
+	}
+
+	if input.MultipartUpload == nil || len(input.MultipartUpload.Parts) == 0 {
+		return nil, apiError("MalformedXML", "no parts given for upload %v", aws.ToString(input.UploadId))
+	}
+
+	data := []byte{}
+	sums := []byte{}
+	last := int32(0)
+
+	for _, part := range input.MultipartUpload.Parts {
+		body, ok := upload.parts[part.PartNumber]
+		if !ok || aws.ToString(part.ETag) != etag(body) {
+			return nil, apiError("InvalidPart", "part %d of upload %v is missing", part.PartNumber, aws.ToString(input.UploadId))
+		}
+
+		if part.PartNumber <= last {
+			return nil, apiError("InvalidPartOrder", "parts of upload %v are not in ascending order", aws.ToString(input.UploadId))
+		}
+		last = part.PartNumber
+
+		data = append(data, body...)
+		sum := md5.Sum(body)
+		sums = append(sums, sum[:]...)
+	}
+
+	sum := md5.Sum(sums)
+	obj := &Object{
+		Key:             aws.ToString(input.Key),
+		Body:            data,
+		ETag:            fmt.Sprintf(`"%v-%d"`, hex.EncodeToString(sum[:]), len(input.MultipartUpload.Parts)),
+		ContentType:     aws.ToString(upload.input.ContentType),
+		ContentEncoding: aws.ToString(upload.input.ContentEncoding),
+		CacheControl:    aws.ToString(upload.input.CacheControl),
+		Metadata:        upload.input.Metadata,
+		LastModified:    time.Now(),
+	}
+	id := f.store(b, obj)
+	delete(b.uploads, aws.ToString(input.UploadId))
+
+	return &s3.CompleteMultipartUploadOutput{
+		Bucket:    input.Bucket,
+		Key:       input.Key,
+		ETag:      aws.String(obj.ETag),
+		VersionId: aws.String(id),
+	}, nil
+}
+
+func (f *S3) AbortMultipartUpload(ctx context.Context, input *s3.AbortMultipartUploadInput, optFns ...func(*s3.Options)) (*s3.AbortMultipartUploadOutput, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	b, _, err := f.upload(input.Bucket, input.UploadId)
+	if err != nil {
+		return nil, err
+	}
+
+	delete(b.uploads, aws.ToString(input.UploadId))
+
+	return &s3.AbortMultipartUploadOutput{}, nil
+}
@@ -3,14 +3,19 @@ module github.com/pilot-framework/aws-cloudfront-waypoint-plugin
 go 1.14
 
 require (
-	github.com/aws/aws-sdk-go-v2 v1.7.0 // indirect
-	github.com/aws/aws-sdk-go-v2/config v1.4.1
-	github.com/aws/aws-sdk-go-v2/service/cloudfront v1.6.0 // indirect
-	github.com/aws/aws-sdk-go-v2/service/s3 v1.11.0
-	github.com/gabriel-vasile/mimetype v1.3.1 // indirect
-	github.com/golang/protobuf v1.5.2 // indirect
+	github.com/andybalholm/brotli v1.0.4
+	github.com/aws/aws-sdk-go-v2 v1.17.3
+	github.com/aws/aws-sdk-go-v2/config v1.18.8
+	github.com/aws/aws-sdk-go-v2/credentials v1.13.8
+	github.com/aws/aws-sdk-go-v2/service/acm v1.17.1
+	github.com/aws/aws-sdk-go-v2/service/cloudfront v1.23.0
+	github.com/aws/aws-sdk-go-v2/service/iam v1.18.25
+	github.com/aws/aws-sdk-go-v2/service/route53 v1.25.2
+	github.com/aws/aws-sdk-go-v2/service/s3 v1.30.0
+	github.com/aws/aws-sdk-go-v2/service/sts v1.18.0
+	github.com/aws/smithy-go v1.13.5
+	github.com/gabriel-vasile/mimetype v1.3.1
 	github.com/hashicorp/waypoint-plugin-sdk v0.0.0-20210625180209-eda7ae600c2d
-	google.golang.org/grpc/cmd/protoc-gen-go-grpc v1.1.0 // indirect
 	google.golang.org/protobuf v1.27.1
 )
 
@@ -1,71 +1,65 @@
 package platform
 
-// Comment out the following functions to implement Authentication in
-// your component
+import (
+	"context"
 
-//func (p *Platform) ValidateAuthFunc() interface{} {
-//	return p.validateAuth
-//}
-//
-//// AuthFunc satisfies the Authenticator interface
-//func (p *Platform) AuthFunc() interface{} {
-//	return r.authenticate
-//}
+	"github.com/hashicorp/waypoint-plugin-sdk/component"
+	"github.com/hashicorp/waypoint-plugin-sdk/terminal"
+	"github.com/pilot-framework/aws-cloudfront-waypoint-plugin/awsclient"
+	"github.com/pilot-framework/aws-cloudfront-waypoint-plugin/creds"
+)
+
+func (p *Platform) ValidateAuthFunc() interface{} {
+	return p.validateAuth
+}
+
+// AuthFunc satisfies the Authenticator interface
+func (p *Platform) AuthFunc() interface{} {
+	return p.authenticate
+}
+
+// RequiredActions returns the IAM actions deploying and destroying need.
+func (p *Platform) RequiredActions() []string {
+	actions := []string{
+		"s3:AbortMultipartUpload",
+		"s3:CreateBucket",
+		"s3:DeleteBucket",
+		"s3:DeleteObject",
+		"s3:DeleteObjectVersion",
+		"s3:GetBucketVersioning",
+		"s3:GetObject",
+		"s3:ListBucket",
+		"s3:ListBucketVersions",
+		"s3:PutObject",
+	}
+
+	if p.config.Access == AccessOAC {
+		return append(actions, "s3:PutBucketPublicAccessBlock")
+	}
+
+	return append(actions, "s3:PutBucketPolicy", "s3:PutBucketWebsite")
+}
 
-// A ValidateAuthFunc does not have a strict signature, you can define the parameters
-// you need based on the Available parameters that the Waypoint SDK provides.
-// Waypoint will automatically inject parameters as specified
-// in the signature at run time.
-//
-// Available input parameters:
-// - context.Context
-// - *component.Source
-// - *component.JobInfo
-// - *component.DeploymentConfig
-// - *datadir.Project
-// - *datadir.App
-// - *datadir.Component
-// - hclog.Logger
-// - terminal.UI
-// - *component.LabelSet
-//
 // If an error is returned, Waypoint will attempt to call
 // AuthFunc
-//func (p *Platform) validateAuth(
-//	ctx context.Context,
-//	ui terminal.UI,
-//) error {
-//	s := ui.Status()
-//	defer s.Close()
-//	s.Update("Validate authentication")
-//
-//	return fmt.Errorf("Unable to Authenticate")
-//}
+func (p *Platform) validateAuth(ctx context.Context, ui terminal.UI) error {
+	// S3 stand-ins accept any credentials
+	if p.config.Endpoint != "" {
+		return nil
+	}
+
+	clients, err := awsclient.New(ctx, clientSettings(&p.config, p.config.Region))
+	if err != nil {
+		ui.Output("AWS configuration error, "+err.Error(), terminal.WithErrorStyle())
+		return err
+	}
+
+	return creds.Validate(ctx, ui, clients.STS(), clients.IAM(), p.RequiredActions())
+}
 
-// A AuthFunc does not have a strict signature, you can define the parameters
-// you need based on the Available parameters that the Waypoint SDK provides.
-// Waypoint will automatically inject parameters as specified
-// in the signature at run time.
-//
-// Available input parameters:
-// - context.Context
-// - *component.Source
-// - *component.JobInfo
-// - *component.DeploymentConfig
-// - *datadir.Project
-// - *datadir.App
-// - *datadir.Component
-// - hclog.Logger
-// - terminal.UI
-// - *component.LabelSet
-//
 // Output parameters must be *component.AuthResult, error
-//func (p *Platform) authenticate(
-//	ctx context.Context,
-//	ui terminal.UI,
-//) (*component.AuthResult, error) {
-//
-//	ui.Output("Describe the manual authentication steps here")
-//
-//	return &component.AuthResult{Authenticated: false}, nil
-//}
+func (p *Platform) authenticate(ctx context.Context, ui terminal.UI) (*component.AuthResult, error) {
+	ui.Output(creds.Guidance(p.RequiredActions()))
+
+	return &component.AuthResult{Authenticated: false}, nil
+}
@@ -0,0 +1,36 @@
+package platform_test
+
+import (
+	"testing"
+
+	"github.com/pilot-framework/aws-cloudfront-waypoint-plugin/platform"
+)
+
+func TestRequiredActions(t *testing.T) {
+	tests := []struct {
+		access   string
+		required []string
+		excluded []string
+	}{
+		{platform.AccessPublic, []string{"s3:PutBucketPolicy", "s3:PutBucketWebsite"}, []string{"s3:PutBucketPublicAccessBlock"}},
+		{platform.AccessOAC, []string{"s3:PutBucketPublicAccessBlock"}, []string{"s3:PutBucketPolicy", "s3:PutBucketWebsite"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.access, func(t *testing.T) {
+			p := newPlatform(t, platform.PlatformConfig{Region: "us-east-1", BucketName: "site", BuildDir: t.TempDir(), Access: tt.access})
+			actions := p.RequiredActions()
+
+			for _, action := range append([]string{"s3:DeleteObjectVersion", "s3:ListBucketVersions", "s3:GetObject"}, tt.required...) {
+				if !contains(actions, action) {
+					t.Errorf("%v is not required", action)
+				}
+			}
+			for _, action := range tt.excluded {
+				if contains(actions, action) {
+					t.Errorf("%v is required", action)
+				}
+			}
+		})
+	}
+}
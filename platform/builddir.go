@@ -0,0 +1,43 @@
+package platform
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/hashicorp/waypoint-plugin-sdk/component"
+)
+
+// ResolveBuildDir returns the directory to upload. A relative directory is
+// resolved against base if set, otherwise against the path of the app source
+// Waypoint injects, and the working directory when there is none.
+func ResolveBuildDir(dir string, base string, src *component.Source) string {
+	if filepath.IsAbs(dir) {
+		return filepath.Clean(dir)
+	}
+
+	root := base
+	if root == "" && src != nil {
+		root = src.Path
+	}
+
+	return filepath.Join(root, dir)
+}
+
+// CheckBuildDir fails with an error naming the directory if it does not exist.
+// It is only checked on deploy, destroying does not need the build.
+func CheckBuildDir(dir string) error {
+	info, err := os.Stat(dir)
+	if os.IsNotExist(err) {
+		return fmt.Errorf("build directory %v does not exist, check the directory setting or run the build first", dir)
+	}
+	if err != nil {
+		return fmt.Errorf("build directory %v cannot be read: %w", dir, err)
+	}
+
+	if !info.IsDir() {
+		return fmt.Errorf("build directory %v is not a directory", dir)
+	}
+
+	return nil
+}
@@ -0,0 +1,151 @@
+package platform
+
+import (
+	"fmt"
+	"path"
+	"regexp"
+	"strings"
+)
+
+const (
+	// CacheProfileHashed applies ImmutableCacheControl to files with a
+	// content hash in their name when no rule matches. This is the default.
+	CacheProfileHashed = "hashed"
+	// CacheProfileHashedBase64 also treats the base64url hashes of vite and
+	// rollup as content hashes. They are harder to tell apart from version
+	// and size suffixes, so this is opt-in.
+	CacheProfileHashedBase64 = "hashed-base64"
+	// CacheProfileNone leaves Cache-Control unset when no rule matches.
+	CacheProfileNone = "none"
+
+	// ImmutableCacheControl caches an object for a year without revalidation
+	ImmutableCacheControl = "public, max-age=31536000, immutable"
+)
+
+var (
+	// webpack and parcel hash in hex, e.g. main.3f2a1b9c.js
+	hexHash = regexp.MustCompile(`[.\-_]([0-9a-f]{8,32})\.[0-9A-Za-z]+$`)
+	// vite and rollup use 8 base64url characters, e.g. index-Bk3xF9aQ.js.
+	// Hashes with - or _ are not detected, they cannot be told apart from
+	// names like og-image-v2.png.
+	base64Hash = regexp.MustCompile(`[.\-_]([0-9A-Za-z]{8})\.[0-9A-Za-z]+$`)
+
+	// dates and image sizes of the same shape, e.g. photo-20230115.jpg or
+	// hero-1024x768.jpg
+	numeric    = regexp.MustCompile(`^[0-9_\-]+$`)
+	dimensions = regexp.MustCompile(`^[0-9]+x[0-9]+$`)
+	digit      = regexp.MustCompile(`[0-9]`)
+	letter     = regexp.MustCompile(`[A-Za-z]`)
+)
+
+// CacheRule sets the Cache-Control header of every object whose key
+// matches the glob pattern, e.g.
+//
+//	cache_control "static/**/*.js" {
+//	  value = "public, max-age=31536000, immutable"
+//	}
+type CacheRule struct {
+	Pattern string `hcl:"pattern,label"`
+	Value   string `hcl:"value"`
+}
+
+// validateCacheRules checks the cache_control blocks and profile of a PlatformConfig.
+func validateCacheRules(rules []*CacheRule, profile string) error {
+	switch profile {
+	case "", CacheProfileHashed, CacheProfileHashedBase64, CacheProfileNone:
+	default:
+		return fmt.Errorf("cache_profile must be %q, %q or %q, got: %v", CacheProfileHashed, CacheProfileHashedBase64, CacheProfileNone, profile)
+	}
+
+	for _, rule := range rules {
+		for _, segment := range strings.Split(rule.Pattern, "/") {
+			if _, err := path.Match(segment, ""); err != nil {
+				return fmt.Errorf("invalid cache_control pattern %q: %v", rule.Pattern, err)
+			}
+		}
+
+		if rule.Value == "" {
+			return fmt.Errorf("cache_control %q must set a value", rule.Pattern)
+		}
+	}
+
+	return nil
+}
+
+// CacheControlFor returns the Cache-Control value for an object key. The
+// first matching rule wins, otherwise the profile decides. An empty result
+// means the header is not set.
+func CacheControlFor(key string, rules []*CacheRule, profile string) string {
+	for _, rule := range rules {
+		if MatchGlob(rule.Pattern, key) {
+			return rule.Value
+		}
+	}
+
+	if profile != CacheProfileNone && IsHashedFilename(key, profile == CacheProfileHashedBase64) {
+		return ImmutableCacheControl
+	}
+
+	return ""
+}
+
+// IsHashedFilename reports whether the file name contains a hex content
+// hash as produced by most bundlers, or with base64 a base64url one. A
+// base64url hash must mix digits and letters and not look like a size.
+func IsHashedFilename(name string, base64 bool) bool {
+	base := path.Base(name)
+
+	if m := hexHash.FindStringSubmatch(base); m != nil && !numeric.MatchString(m[1]) {
+		return true
+	}
+
+	if !base64 {
+		return false
+	}
+
+	m := base64Hash.FindStringSubmatch(base)
+
+	return m != nil && digit.MatchString(m[1]) && letter.MatchString(m[1]) && !dimensions.MatchString(m[1])
+}
+
+// MatchGlob matches an object key against a slash separated glob pattern.
+// Each segment follows path.Match and `**` matches any number of segments.
+// A pattern without a slash is matched against the base name of the key,
+// so `*.html` applies at every depth.
+func MatchGlob(pattern, key string) bool {
+	pattern = strings.TrimPrefix(pattern, "/")
+
+	if !strings.Contains(pattern, "/") && pattern != "**" {
+		ok, err := path.Match(pattern, path.Base(key))
+		return err == nil && ok
+	}
+
+	return matchSegments(strings.Split(pattern, "/"), strings.Split(key, "/"))
+}
+
+func matchSegments(pattern, parts []string) bool {
+	for len(pattern) > 0 {
+		if pattern[0] == "**" {
+			for i := 0; i <= len(parts); i++ {
+				if matchSegments(pattern[1:], parts[i:]) {
+					return true
+				}
+			}
+
+			return false
+		}
+
+		if len(parts) == 0 {
+			return false
+		}
+
+		ok, err := path.Match(pattern[0], parts[0])
+		if err != nil || !ok {
+			return false
+		}
+
+		pattern, parts = pattern[1:], parts[1:]
+	}
+
+	return len(parts) == 0
+}
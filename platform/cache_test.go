@@ -0,0 +1,67 @@
+package platform
+
+import "testing"
+
+func TestIsHashedFilename(t *testing.T) {
+	tests := []struct {
+		name   string
+		base64 bool
+		want   bool
+	}{
+		{"static/js/main.3f2a1b9c.js", false, true},
+		{"main.3f2a1b9c4d5e6f708192.js", false, true},
+		{"static/js/main.3f2a1b9c.js", true, true},
+		{"assets/index-Bk3xF9aQ.css", false, false},
+		{"assets/index-Bk3xF9aQ.css", true, true},
+		{"assets/vendor_DmX7q07Z.js", true, true},
+		{"assets/index-B_x3F-9a.js", true, false},
+		{"assets/index-BkqwFaQz.css", true, false},
+		{"index.html", true, false},
+		{"app-settings.js", true, false},
+		{"img/photo-20230115.jpg", true, false},
+		{"img/photo-2023-01-15.jpg", true, false},
+		{"img/photo_20230115123045.jpg", true, false},
+		{"docs/report_2024Q1summary.pdf", true, false},
+		{"fonts/Overview.woff2", true, false},
+		{"js/Download-manager.js", true, false},
+		{"img/og-image-v2.png", true, false},
+		{"img/logo-v2-final.png", true, false},
+		{"photos/photo_IMG_1234.jpg", true, false},
+		{"img/hero-1024x768.jpg", true, false},
+	}
+
+	for _, tt := range tests {
+		if got := IsHashedFilename(tt.name, tt.base64); got != tt.want {
+			t.Errorf("IsHashedFilename(%q, %v) = %v, want %v", tt.name, tt.base64, got, tt.want)
+		}
+	}
+}
+
+func TestCacheControlFor(t *testing.T) {
+	rules := []*CacheRule{
+		{Pattern: "index.html", Value: "no-cache"},
+		{Pattern: "static/**/*.js", Value: "public, max-age=60"},
+	}
+
+	tests := []struct {
+		key     string
+		profile string
+		want    string
+	}{
+		{"index.html", "", "no-cache"},
+		{"docs/index.html", "", "no-cache"},
+		{"static/js/main.3f2a1b9c.js", "", "public, max-age=60"},
+		{"js/main.3f2a1b9c.js", "", ImmutableCacheControl},
+		{"js/main.3f2a1b9c.js", CacheProfileNone, ""},
+		{"assets/index-Bk3xF9aQ.js", "", ""},
+		{"assets/index-Bk3xF9aQ.js", CacheProfileHashed, ""},
+		{"assets/index-Bk3xF9aQ.js", CacheProfileHashedBase64, ImmutableCacheControl},
+		{"img/photo-20230115.jpg", CacheProfileHashedBase64, ""},
+	}
+
+	for _, tt := range tests {
+		if got := CacheControlFor(tt.key, rules, tt.profile); got != tt.want {
+			t.Errorf("CacheControlFor(%q, %q) = %q, want %q", tt.key, tt.profile, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,139 @@
+package platform
+
+import (
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/andybalholm/brotli"
+)
+
+// Content encodings supported for uploads
+const (
+	EncodingGzip   = "gzip"
+	EncodingBrotli = "br"
+)
+
+// file extensions of build artifacts that were compressed ahead of time
+var encodingExtensions = map[string]string{
+	EncodingGzip:   ".gz",
+	EncodingBrotli: ".br",
+}
+
+func validateCompression(encoding string, precompressed bool) error {
+	if _, ok := encodingExtensions[encoding]; !ok && encoding != "" {
+		return fmt.Errorf("compression must be %q or %q, got: %v", EncodingGzip, EncodingBrotli, encoding)
+	}
+
+	if precompressed && encoding == "" {
+		return fmt.Errorf("precompressed requires compression to be set")
+	}
+
+	return nil
+}
+
+// IsCompressible reports whether content of the given MIME type is text-like
+// and worth compressing. Images, video and archives are already compressed.
+func IsCompressible(contentType string) bool {
+	mt := strings.TrimSpace(strings.SplitN(contentType, ";", 2)[0])
+
+	if strings.HasPrefix(mt, "text/") || strings.HasSuffix(mt, "+json") || strings.HasSuffix(mt, "+xml") {
+		return true
+	}
+
+	switch mt {
+	case "application/javascript",
+		"application/json",
+		"application/xml",
+		"application/wasm",
+		"font/ttf",
+		"font/otf",
+		"application/vnd.ms-fontobject":
+		return true
+	}
+
+	return false
+}
+
+// Compress encodes data with gzip or brotli at their highest levels.
+func Compress(data []byte, encoding string) ([]byte, error) {
+	var buf bytes.Buffer
+	var w io.WriteCloser
+
+	switch encoding {
+	case EncodingGzip:
+		gz, err := gzip.NewWriterLevel(&buf, gzip.BestCompression)
+		if err != nil {
+			return nil, err
+		}
+		w = gz
+	case EncodingBrotli:
+		w = brotli.NewWriterLevel(&buf, brotli.BestCompression)
+	default:
+		return nil, fmt.Errorf("unsupported content encoding %q", encoding)
+	}
+
+	if _, err := w.Write(data); err != nil {
+		return nil, err
+	}
+
+	if err := w.Close(); err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
+
+// compressBody returns the body to upload for a compressible file and its
+// Content-Encoding. A sibling produced by the build is used as is when
+// precompressed is set. The original is returned with no encoding when
+// compressing does not make it smaller.
+func compressBody(filePath string, data []byte, encoding string, precompressed bool) ([]byte, string, error) {
+	var compressed []byte
+	var err error
+
+	if precompressed {
+		compressed, err = os.ReadFile(filePath + encodingExtensions[encoding])
+		if err != nil && !os.IsNotExist(err) {
+			return nil, "", err
+		}
+	}
+
+	if compressed == nil {
+		compressed, err = Compress(data, encoding)
+		if err != nil {
+			return nil, "", err
+		}
+	}
+
+	if len(compressed) >= len(data) {
+		return data, "", nil
+	}
+
+	return compressed, encoding, nil
+}
+
+// dropCompressedSiblings removes the .gz/.br files that sit next to their
+// original from the upload, they are sent in its place by compressBody.
+func dropCompressedSiblings(files []LocalFile, encoding string) []LocalFile {
+	ext := encodingExtensions[encoding]
+
+	keys := make(map[string]bool, len(files))
+	for _, f := range files {
+		keys[f.Key] = true
+	}
+
+	kept := []LocalFile{}
+	for _, f := range files {
+		if strings.HasSuffix(f.Key, ext) && keys[strings.TrimSuffix(f.Key, ext)] {
+			continue
+		}
+
+		kept = append(kept, f)
+	}
+
+	return kept
+}
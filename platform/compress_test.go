@@ -0,0 +1,156 @@
+package platform
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/andybalholm/brotli"
+)
+
+func decompress(t *testing.T, data []byte, encoding string) []byte {
+	t.Helper()
+
+	var r io.Reader
+
+	switch encoding {
+	case EncodingGzip:
+		gz, err := gzip.NewReader(bytes.NewReader(data))
+		if err != nil {
+			t.Fatal(err)
+		}
+		r = gz
+	case EncodingBrotli:
+		r = brotli.NewReader(bytes.NewReader(data))
+	default:
+		t.Fatalf("unexpected encoding %q", encoding)
+	}
+
+	out, err := io.ReadAll(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return out
+}
+
+func TestCompress(t *testing.T) {
+	data := []byte(strings.Repeat("body { margin: 0; }\n", 100))
+
+	for _, encoding := range []string{EncodingGzip, EncodingBrotli} {
+		t.Run(encoding, func(t *testing.T) {
+			compressed, err := Compress(data, encoding)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if len(compressed) >= len(data) {
+				t.Errorf("compressed to %d bytes from %d", len(compressed), len(data))
+			}
+			if got := decompress(t, compressed, encoding); !bytes.Equal(got, data) {
+				t.Errorf("round trip = %q", got)
+			}
+		})
+	}
+
+	if _, err := Compress(data, "deflate"); err == nil {
+		t.Error("compressed with an unsupported encoding")
+	}
+}
+
+func TestCompressBody(t *testing.T) {
+	data := []byte(strings.Repeat("console.log('hello')\n", 100))
+	sibling := []byte("precompressed by the build")
+
+	tests := []struct {
+		name          string
+		data          []byte
+		encoding      string
+		precompressed bool
+		// sibling is written next to the file with the encoding's extension
+		sibling []byte
+		body    []byte
+		want    string
+	}{
+		{name: "gzip", data: data, encoding: EncodingGzip, want: EncodingGzip},
+		{name: "brotli", data: data, encoding: EncodingBrotli, want: EncodingBrotli},
+		{name: "gzip sibling", data: data, encoding: EncodingGzip, precompressed: true, sibling: sibling, body: sibling, want: EncodingGzip},
+		{name: "brotli sibling", data: data, encoding: EncodingBrotli, precompressed: true, sibling: sibling, body: sibling, want: EncodingBrotli},
+		{name: "sibling ignored", data: data, encoding: EncodingGzip, sibling: sibling, want: EncodingGzip},
+		{name: "missing sibling", data: data, encoding: EncodingBrotli, precompressed: true, want: EncodingBrotli},
+		{name: "not smaller", data: []byte("a"), encoding: EncodingGzip, body: []byte("a")},
+		{name: "sibling not smaller", data: []byte("ab"), encoding: EncodingGzip, precompressed: true, sibling: []byte("abc"), body: []byte("ab")},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "app.js")
+			if err := os.WriteFile(path, tt.data, 0644); err != nil {
+				t.Fatal(err)
+			}
+			if tt.sibling != nil {
+				if err := os.WriteFile(path+encodingExtensions[tt.encoding], tt.sibling, 0644); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			body, encoding, err := compressBody(path, tt.data, tt.encoding, tt.precompressed)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if encoding != tt.want {
+				t.Errorf("encoding = %q, want %q", encoding, tt.want)
+			}
+
+			switch {
+			case tt.body != nil:
+				if !bytes.Equal(body, tt.body) {
+					t.Errorf("body = %q, want %q", body, tt.body)
+				}
+			case !bytes.Equal(decompress(t, body, encoding), tt.data):
+				t.Error("body does not decompress to the file")
+			}
+		})
+	}
+}
+
+func TestDropCompressedSiblings(t *testing.T) {
+	files := []LocalFile{
+		{Key: "app.js"},
+		{Key: "app.js.gz"},
+		{Key: "app.js.br"},
+		{Key: "style.css.br"},
+		{Key: "archive.tar.gz"},
+	}
+
+	keys := func(files []LocalFile) []string {
+		out := []string{}
+		for _, f := range files {
+			out = append(out, f.Key)
+		}
+
+		return out
+	}
+
+	tests := []struct {
+		encoding string
+		want     []string
+	}{
+		{EncodingGzip, []string{"app.js", "app.js.br", "style.css.br", "archive.tar.gz"}},
+		{EncodingBrotli, []string{"app.js", "app.js.gz", "style.css.br", "archive.tar.gz"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.encoding, func(t *testing.T) {
+			if got := keys(dropCompressedSiblings(files, tt.encoding)); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("kept %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -1,19 +1,18 @@
 package platform
 
 import (
-	"bytes"
 	"context"
 	"fmt"
-	"os"
-	"path"
+	"path/filepath"
 	"strings"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
-	"github.com/aws/aws-sdk-go-v2/config"
 	"github.com/aws/aws-sdk-go-v2/service/s3"
 	"github.com/aws/aws-sdk-go-v2/service/s3/types"
-	"github.com/gabriel-vasile/mimetype"
+	"github.com/hashicorp/waypoint-plugin-sdk/component"
 	"github.com/hashicorp/waypoint-plugin-sdk/terminal"
+	"github.com/pilot-framework/aws-cloudfront-waypoint-plugin/awsclient"
+	"github.com/pilot-framework/aws-cloudfront-waypoint-plugin/creds"
 )
 
 // S3BucketAPI defines the interface for the CreateBucket function.
@@ -26,6 +25,10 @@ type S3BucketAPI interface {
 		params *s3.PutBucketPolicyInput,
 		optFns ...func(*s3.Options)) (*s3.PutBucketPolicyOutput, error)
 
+	PutPublicAccessBlock(ctx context.Context,
+		params *s3.PutPublicAccessBlockInput,
+		optFns ...func(*s3.Options)) (*s3.PutPublicAccessBlockOutput, error)
+
 	PutBucketWebsite(ctx context.Context,
 		params *s3.PutBucketWebsiteInput,
 		optFns ...func(*s3.Options)) (*s3.PutBucketWebsiteOutput, error)
@@ -34,6 +37,26 @@ type S3BucketAPI interface {
 		params *s3.PutObjectInput,
 		optFns ...func(*s3.Options)) (*s3.PutObjectOutput, error)
 
+	CreateMultipartUpload(ctx context.Context,
+		params *s3.CreateMultipartUploadInput,
+		optFns ...func(*s3.Options)) (*s3.CreateMultipartUploadOutput, error)
+	UploadPart(ctx context.Context,
+		params *s3.UploadPartInput,
+		optFns ...func(*s3.Options)) (*s3.UploadPartOutput, error)
+	CompleteMultipartUpload(ctx context.Context,
+		params *s3.CompleteMultipartUploadInput,
+		optFns ...func(*s3.Options)) (*s3.CompleteMultipartUploadOutput, error)
+	AbortMultipartUpload(ctx context.Context,
+		params *s3.AbortMultipartUploadInput,
+		optFns ...func(*s3.Options)) (*s3.AbortMultipartUploadOutput, error)
+
+	HeadObject(ctx context.Context,
+		params *s3.HeadObjectInput,
+		optFns ...func(*s3.Options)) (*s3.HeadObjectOutput, error)
+	GetObject(ctx context.Context,
+		params *s3.GetObjectInput,
+		optFns ...func(*s3.Options)) (*s3.GetObjectOutput, error)
+
 	GetBucketAcl(ctx context.Context,
 		params *s3.GetBucketAclInput,
 		optFns ...func(*s3.Options)) (*s3.GetBucketAclOutput, error)
@@ -43,6 +66,15 @@ type S3BucketAPI interface {
 	DeleteObject(ctx context.Context,
 		params *s3.DeleteObjectInput,
 		optFns ...func(*s3.Options)) (*s3.DeleteObjectOutput, error)
+	DeleteObjects(ctx context.Context,
+		params *s3.DeleteObjectsInput,
+		optFns ...func(*s3.Options)) (*s3.DeleteObjectsOutput, error)
+	ListObjectVersions(ctx context.Context,
+		params *s3.ListObjectVersionsInput,
+		optFns ...func(*s3.Options)) (*s3.ListObjectVersionsOutput, error)
+	GetBucketVersioning(ctx context.Context,
+		params *s3.GetBucketVersioningInput,
+		optFns ...func(*s3.Options)) (*s3.GetBucketVersioningOutput, error)
 	DeleteBucket(ctx context.Context,
 		params *s3.DeleteBucketInput,
 		optFns ...func(*s3.Options)) (*s3.DeleteBucketOutput, error)
@@ -68,6 +100,10 @@ func SetPublicBucketPolicy(c context.Context, api S3BucketAPI, input *s3.PutBuck
 	return api.PutBucketPolicy(c, input)
 }
 
+func SetPublicAccessBlock(c context.Context, api S3BucketAPI, input *s3.PutPublicAccessBlockInput) (*s3.PutPublicAccessBlockOutput, error) {
+	return api.PutPublicAccessBlock(c, input)
+}
+
 func EnableWebHosting(c context.Context, api S3BucketAPI, input *s3.PutBucketWebsiteInput) (*s3.PutBucketWebsiteOutput, error) {
 	return api.PutBucketWebsite(c, input)
 }
@@ -76,6 +112,10 @@ func AddFile(c context.Context, api S3BucketAPI, input *s3.PutObjectInput) (*s3.
 	return api.PutObject(c, input)
 }
 
+func GetItem(c context.Context, api S3BucketAPI, input *s3.GetObjectInput) (*s3.GetObjectOutput, error) {
+	return api.GetObject(c, input)
+}
+
 func DeleteItem(c context.Context, api S3BucketAPI, input *s3.DeleteObjectInput) (*s3.DeleteObjectOutput, error) {
 	return api.DeleteObject(c, input)
 }
@@ -88,15 +128,93 @@ func DeleteBucket(c context.Context, api S3BucketAPI, input *s3.DeleteBucketInpu
 	return api.DeleteBucket(c, input)
 }
 
+// Ways CloudFront can read from the bucket
+const (
+	// AccessPublic serves the bucket as a public S3 website
+	AccessPublic = "public"
+	// AccessOAC keeps the bucket private, only the distribution can read it
+	// through an Origin Access Control
+	AccessOAC = "oac"
+)
+
+// DeploymentsPrefix is where immutable deployments are uploaded to, each
+// under the ID Waypoint assigned to it
+const DeploymentsPrefix = "deployments/"
+
+// MaxChangedKeys is the number of changed keys above which a deployment
+// does not record them, the release then invalidates everything.
+const MaxChangedKeys = 1000
+
+// DeploymentPrefix returns the key prefix of the deployment with the given ID
+func DeploymentPrefix(id string) string {
+	return DeploymentsPrefix + id + "/"
+}
+
 type PlatformConfig struct {
 	// AWS region to operate in
 	Region string `hcl:"region"`
 
 	// Name of S3 bucket to create
 	BucketName string `hcl:"bucket"`
-	// Where build directory is located
+	// Where build directory is located, relative to the app unless absolute
 	BuildDir string `hcl:"directory"`
-	BaseDir  string `hcl:"base,optional"`
+	// Absolute path relative build directories are resolved against instead of the app
+	BaseDir string `hcl:"base,optional"`
+
+	// How CloudFront reads the bucket, "public" (default) or "oac"
+	Access string `hcl:"access,optional"`
+
+	// Upload every deployment to its own prefix under deployments/ instead
+	// of the bucket root, the release switches the distribution to it
+	Immutable bool `hcl:"immutable,optional"`
+	// Number of the newest destroyed deployments kept for rollbacks, older
+	// destroyed ones are deleted. Without it the destroyed deployment is
+	// deleted right away. Deployments that were not destroyed are never deleted
+	Retain int `hcl:"retain,optional"`
+
+	// Number of files uploaded in parallel
+	Concurrency int `hcl:"concurrency,optional"`
+	// Only upload files that are new or differ from the bucket contents
+	Sync bool `hcl:"sync,optional"`
+	// Delete objects that are no longer part of the build
+	Prune *PruneConfig `hcl:"prune,block"`
+
+	// Cache-Control values by key pattern, first match wins
+	CacheControl []*CacheRule `hcl:"cache_control,block"`
+	// Fallback for objects no rule matches, "hashed" (default),
+	// "hashed-base64" to also detect vite and rollup hashes, or "none"
+	CacheProfile string `hcl:"cache_profile,optional"`
+
+	// Compress text-like files before upload, "gzip" or "br"
+	Compression string `hcl:"compression,optional"`
+	// Upload the .gz/.br files produced by the build instead of compressing
+	Precompressed bool `hcl:"precompressed,optional"`
+
+	// MIME types by file extension, take precedence over the built-in table
+	ContentTypes map[string]string `hcl:"content_types,optional"`
+
+	// Files of this many MiB or more are sent as multipart uploads, at most
+	// 5120 as S3 does not accept larger single uploads
+	MultipartThresholdMB int `hcl:"multipart_threshold_mb,optional"`
+
+	// S3 compatible endpoint to use instead of AWS, e.g. LocalStack or MinIO
+	Endpoint string `hcl:"endpoint,optional"`
+	// Address buckets in the URL path instead of the host name
+	ForcePathStyle bool `hcl:"force_path_style,optional"`
+
+	// Named profile from the shared AWS config files
+	Profile string `hcl:"profile,optional"`
+	// IAM role to assume with the profile's credentials, e.g. in another account
+	RoleArn string `hcl:"role_arn,optional"`
+	// External ID the trust policy of the role requires
+	ExternalId string `hcl:"external_id,optional"`
+	// Session name of the assumed role, defaults to pilot-waypoint
+	SessionName string `hcl:"session_name,optional"`
+
+	// Retry mode of the AWS clients, "standard" (default) or "adaptive"
+	RetryMode string `hcl:"retry_mode,optional"`
+	// Attempts per request including the first, defaults to 3
+	MaxAttempts int `hcl:"max_attempts,optional"`
 }
 
 type Platform struct {
@@ -116,44 +234,72 @@ func (p *Platform) ConfigSet(config interface{}) error {
 		return fmt.Errorf("expected *PlatformConfig as parameter")
 	}
 
-	tmpFiles, err := os.ReadDir("/tmp")
-	if err != nil {
-		return fmt.Errorf("error accessing tmp directory")
+	if c.BuildDir == "" {
+		return fmt.Errorf("directory must be specified")
 	}
 
-	tmpDir := ""
+	if c.BaseDir != "" && !filepath.IsAbs(c.BaseDir) {
+		return fmt.Errorf("base must be an absolute path, got: %v", c.BaseDir)
+	}
 
-	for _, file := range tmpFiles {
-		if file.IsDir() && strings.Contains(file.Name(), "waypoint") {
-			tmpDir = file.Name()
-			break
-		}
+	if c.Region == "" {
+		return fmt.Errorf("region must be specified")
 	}
 
-	if tmpDir == "" {
-		return fmt.Errorf("could not find tmp directory for this project")
+	if c.BucketName == "" {
+		return fmt.Errorf("bucket name must be specified")
 	}
 
-	c.BaseDir = path.Join("/tmp", tmpDir)
-	c.BuildDir = path.Join(c.BaseDir, strings.TrimLeft(c.BuildDir, "./"))
+	switch c.Access {
+	case "":
+		c.Access = AccessPublic
+	case AccessPublic, AccessOAC:
+	default:
+		return fmt.Errorf("access must be %q or %q, got: %v", AccessPublic, AccessOAC, c.Access)
+	}
 
-	// TODO: find graceful way to check if in destroy phase
-	// and ignore this error
-	// _, err = os.Stat(c.BuildDir)
+	if c.Immutable && c.Prune != nil {
+		return fmt.Errorf("prune cannot be used with immutable, every deployment starts from an empty prefix")
+	}
 
-	// // validate the config
-	// if err != nil {
-	// 	return fmt.Errorf("no build directory exists, got: %v", c.BuildDir)
-	// }
+	if c.Retain < 0 {
+		return fmt.Errorf("retain must not be negative, got: %v", c.Retain)
+	}
 
-	if c.Region == "" {
-		return fmt.Errorf("region must be specified")
+	if c.Retain > 0 && !c.Immutable {
+		return fmt.Errorf("retain requires immutable deployments")
 	}
 
-	if c.BucketName == "" {
-		return fmt.Errorf("bucket name must be specified")
+	if c.Concurrency < 0 {
+		return fmt.Errorf("concurrency must not be negative, got: %v", c.Concurrency)
+	}
+
+	if err := validateCacheRules(c.CacheControl, c.CacheProfile); err != nil {
+		return err
 	}
 
+	if err := validateCompression(c.Compression, c.Precompressed); err != nil {
+		return err
+	}
+
+	if c.MultipartThresholdMB < 0 || c.MultipartThresholdMB > MaxMultipartThreshold>>20 {
+		return fmt.Errorf("multipart_threshold_mb must be between 0 and %v, got: %v", MaxMultipartThreshold>>20, c.MultipartThresholdMB)
+	}
+
+	if err := awsclient.ValidateEndpoint("endpoint", c.Endpoint); err != nil {
+		return err
+	}
+
+	if err := clientSettings(c, c.Region).Validate(); err != nil {
+		return err
+	}
+
+	contentTypes, err := normalizeContentTypes(c.ContentTypes)
+	if err != nil {
+		return err
+	}
+	c.ContentTypes = contentTypes
+
 	return nil
 }
 
@@ -163,7 +309,7 @@ func (p *Platform) DeployFunc() interface{} {
 	return p.deploy
 }
 
-func CreateBucket(p *Platform, client *s3.Client) error {
+func CreateBucket(c context.Context, p *Platform, client S3BucketAPI) error {
 	input := &s3.CreateBucketInput{
 		Bucket: &p.config.BucketName,
 	}
@@ -174,7 +320,7 @@ func CreateBucket(p *Platform, client *s3.Client) error {
 		}
 	}
 
-	_, err := MakeBucket(context.TODO(), client, input)
+	_, err := MakeBucket(c, client, input)
 	return err
 }
 
@@ -182,17 +328,45 @@ func BucketExists(err error) bool {
 	return strings.Contains(err.Error(), "NoSuchBucket") || strings.Contains(err.Error(), "BucketAlreadyOwnedByYou")
 }
 
-func PutBucketPolicy(b string, client *s3.Client) error {
+func PutBucketPolicy(c context.Context, b string, client S3BucketAPI) error {
 	input := &s3.PutBucketPolicyInput{
 		Bucket: &b,
 		Policy: aws.String(getPolicy(b)),
 	}
 
-	_, err := SetPublicBucketPolicy(context.TODO(), client, input)
+	_, err := SetPublicBucketPolicy(c, client, input)
+	return err
+}
+
+// PutDistributionBucketPolicy replaces the bucket policy with one that only
+// lets the given CloudFront distributions read objects.
+func PutDistributionBucketPolicy(c context.Context, b string, distributionArns []string, client S3BucketAPI) error {
+	input := &s3.PutBucketPolicyInput{
+		Bucket: &b,
+		Policy: aws.String(getDistributionPolicy(b, distributionArns)),
+	}
+
+	_, err := SetPublicBucketPolicy(c, client, input)
 	return err
 }
 
-func PutBucketWebsite(b string, client *s3.Client) error {
+// BlockPublicAccess turns on all of the bucket's Block Public Access settings.
+func BlockPublicAccess(c context.Context, b string, client S3BucketAPI) error {
+	input := &s3.PutPublicAccessBlockInput{
+		Bucket: &b,
+		PublicAccessBlockConfiguration: &types.PublicAccessBlockConfiguration{
+			BlockPublicAcls:       true,
+			BlockPublicPolicy:     true,
+			IgnorePublicAcls:      true,
+			RestrictPublicBuckets: true,
+		},
+	}
+
+	_, err := SetPublicAccessBlock(c, client, input)
+	return err
+}
+
+func PutBucketWebsite(c context.Context, b string, client S3BucketAPI) error {
 	input := &s3.PutBucketWebsiteInput{
 		Bucket: &b,
 		WebsiteConfiguration: &types.WebsiteConfiguration{
@@ -200,70 +374,95 @@ func PutBucketWebsite(b string, client *s3.Client) error {
 		},
 	}
 
-	_, err := EnableWebHosting(context.TODO(), client, input)
+	_, err := EnableWebHosting(c, client, input)
 	return err
 }
 
-// PutObjects recursively checks for files in build path and uploads
-// to specified s3 bucket. The errors slice keeps track of errors found during upload.
-func PutObjects(b, buildDir, subPath string, client *s3.Client, errors *[]string) []string {
-	files, err := os.ReadDir(path.Join(buildDir, subPath))
+func (p *Platform) deploy(
+	ctx context.Context,
+	ui terminal.UI,
+	src *component.Source,
+	dcr *component.DeploymentConfig,
+) (*Deployment, error) {
+	clients, err := awsclient.New(ctx, clientSettings(&p.config, p.config.Region))
 	if err != nil {
-		*errors = append(*errors, err.Error())
+		ui.Output("AWS configuration error, "+err.Error(), terminal.WithErrorStyle())
+		return nil, err
 	}
 
-	for _, file := range files {
-		if file.IsDir() {
-			PutObjects(b, buildDir, subPath+file.Name()+"/", client, errors)
-			continue
-		}
-
-		f, err := os.Open(path.Join(buildDir, subPath, file.Name()))
+	// recorded so that the release can check it uses the same account,
+	// S3 stand-ins have no accounts
+	account := ""
+	if p.config.Endpoint == "" {
+		account, err = clients.AccountId(ctx)
 		if err != nil {
-			*errors = append(*errors, err.Error())
-			continue
+			ui.Output("Could not identify the AWS account, "+err.Error(), terminal.WithErrorStyle())
+			return nil, err
 		}
+	}
 
-		defer f.Close()
+	deployment, err := p.Deploy(ctx, ui, src, dcr, clients.S3())
+	if err != nil {
+		return nil, err
+	}
 
-		// get file size and read file contents into buffer
-		fileInfo, _ := f.Stat()
-		size := fileInfo.Size()
-		buffer := make([]byte, size)
-		f.Read(buffer)
+	deployment.AccountId = account
 
-		input := &s3.PutObjectInput{
-			Bucket:      &b,
-			Key:         aws.String(subPath + fileInfo.Name()),
-			Body:        bytes.NewReader(buffer),
-			ContentType: aws.String(DetectMimeType(fileInfo.Name(), buffer)),
-		}
+	return deployment, nil
+}
 
-		_, err = AddFile(context.TODO(), client, input)
-		if err != nil {
-			*errors = append(*errors, err.Error())
-			continue
-		}
+// clientSettings configures the AWS clients for a bucket in region
+func clientSettings(c *PlatformConfig, region string) awsclient.Settings {
+	return awsclient.Settings{
+		Region: region,
+		Credentials: creds.Options{
+			Profile:     c.Profile,
+			RoleArn:     c.RoleArn,
+			ExternalId:  c.ExternalId,
+			SessionName: c.SessionName,
+		},
+		RetryMode:      c.RetryMode,
+		MaxAttempts:    c.MaxAttempts,
+		S3Endpoint:     c.Endpoint,
+		ForcePathStyle: c.ForcePathStyle,
 	}
-
-	return *errors
 }
 
-func (p *Platform) deploy(ctx context.Context, ui terminal.UI) (*Deployment, error) {
+// Deploy creates the bucket if needed and uploads the build directory of
+// the app through client. Immutable deployments are uploaded to the prefix
+// of the deployment in dcr.
+func (p *Platform) Deploy(
+	ctx context.Context,
+	ui terminal.UI,
+	src *component.Source,
+	dcr *component.DeploymentConfig,
+	client S3BucketAPI,
+) (*Deployment, error) {
 	u := ui.Status()
 	defer u.Close()
 	u.Step("", "\n---Deploying S3 assets---")
 
-	cfg, err := config.LoadDefaultConfig(context.TODO(), config.WithRegion(p.config.Region))
+	prefix := ""
+	if p.config.Immutable {
+		if dcr == nil || dcr.Id == "" {
+			err := fmt.Errorf("immutable deployments need the deployment ID from Waypoint")
+			u.Step(terminal.StatusError, err.Error())
+			return nil, err
+		}
+
+		prefix = DeploymentPrefix(dcr.Id)
+	}
+
+	buildDir := ResolveBuildDir(p.config.BuildDir, p.config.BaseDir, src)
+
+	err := CheckBuildDir(buildDir)
 	if err != nil {
-		u.Step(terminal.StatusError, "AWS configuration error, "+err.Error())
+		u.Step(terminal.StatusError, err.Error())
 		return nil, err
 	}
 
-	client := s3.NewFromConfig(cfg)
-
 	u.Step("", "Attempting to create bucket "+p.config.BucketName)
-	err = CreateBucket(p, client)
+	err = CreateBucket(ctx, p, client)
 	if err != nil {
 		if BucketExists(err) {
 			u.Step(terminal.StatusOK, "Found existing bucket")
@@ -274,41 +473,203 @@ func (p *Platform) deploy(ctx context.Context, ui terminal.UI) (*Deployment, err
 	}
 	u.Step(terminal.StatusOK, "Bucket created successfully")
 
-	u.Step("", "Setting bucket permissions")
+	if p.config.Access == AccessOAC {
+		u.Step("", "Blocking public access")
 
-	err = PutBucketPolicy(p.config.BucketName, client)
-	if err != nil {
-		u.Step(terminal.StatusError, "Could not set bucket policy")
-		return nil, err
+		err = BlockPublicAccess(ctx, p.config.BucketName, client)
+		if err != nil {
+			u.Step(terminal.StatusError, "Could not block public access")
+			return nil, err
+		}
+
+		u.Step(terminal.StatusOK, "Public access blocked, the bucket policy is set on release")
+	} else {
+		u.Step("", "Setting bucket permissions")
+
+		err = PutBucketPolicy(ctx, p.config.BucketName, client)
+		if err != nil {
+			u.Step(terminal.StatusError, "Could not set bucket policy")
+			return nil, err
+		}
+
+		u.Step(terminal.StatusOK, "Bucket policy created")
+		u.Step("", "Enabling static website hosting")
+
+		err = PutBucketWebsite(ctx, p.config.BucketName, client)
+		if err != nil {
+			u.Step(terminal.StatusError, "Could not enable static web hosting")
+			return nil, err
+		}
+
+		u.Step(terminal.StatusOK, "Static website hosting enabled")
 	}
 
-	u.Step(terminal.StatusOK, "Bucket policy created")
-	u.Step("", "Enabling static website hosting")
+	if prefix != "" {
+		u.Step("", "Pushing static files to "+prefix)
+	} else {
+		u.Step("", "Pushing static files")
+	}
 
-	err = PutBucketWebsite(p.config.BucketName, client)
+	files, err := ListFiles(buildDir)
 	if err != nil {
-		u.Step(terminal.StatusError, "Could not enable static web hosting")
+		u.Step(terminal.StatusError, "Could not read build directory "+buildDir)
 		return nil, err
 	}
 
-	u.Step(terminal.StatusOK, "Static website hosting enabled")
-	u.Step("", "Pushing static files")
+	if p.config.Precompressed {
+		files = dropCompressedSiblings(files, p.config.Compression)
+	}
+
+	opts := UploadOptions{
+		Concurrency:   p.config.Concurrency,
+		CacheRules:    p.config.CacheControl,
+		CacheProfile:  p.config.CacheProfile,
+		Compression:   p.config.Compression,
+		Precompressed: p.config.Precompressed,
+		ContentTypes:  p.config.ContentTypes,
+		Prefix:        prefix,
+
+		MultipartThreshold: int64(p.config.MultipartThresholdMB) << 20,
+		Progress: func(done, total int) {
+			u.Update(fmt.Sprintf("Pushing static files (%d/%d)", done, total))
+		},
+	}
+
+	if p.config.Sync {
+		u.Update("Listing existing objects...")
+
+		opts.Existing, err = ListRemoteObjects(ctx, client, p.config.BucketName, prefix)
+		if err != nil {
+			u.Step(terminal.StatusError, "Could not list objects in bucket "+p.config.BucketName)
+			return nil, err
+		}
+	}
+
+	result := PutObjects(ctx, client, p.config.BucketName, files, opts)
+	if len(result.Failed) > 0 {
+		for _, fileErr := range result.Failed {
+			u.Step(terminal.StatusError, fileErr.Error())
+		}
+		// a partial deployment must never be released
+		if prefix != "" {
+			u.Step(terminal.StatusError, "Some static files failed to upload to "+prefix)
+			return nil, result.Failed
+		}
 
-	fileErrors := []string{}
-	PutObjects(p.config.BucketName, p.config.BuildDir, "", client, &fileErrors)
-	if len(fileErrors) > 0 {
-		u.Step(terminal.StatusError, fmt.Sprintf("%v", fileErrors))
 		u.Step(terminal.StatusWarn, "Some static files failed to upload")
 	}
 
-	u.Step(terminal.StatusOK, "Upload of static files complete")
+	u.Step(terminal.StatusOK, fmt.Sprintf(
+		"Upload of static files complete, %d uploaded, %d skipped, %d failed",
+		len(result.Uploaded),
+		len(result.Skipped),
+		len(result.Failed),
+	))
+
+	// the release invalidates these paths in the CDN, switching to a new
+	// prefix changes every path
+	changed := result.Uploaded
+	known := prefix == ""
+
+	if p.config.Prune != nil {
+		pruned, err := pruneObjects(ctx, u, client, p.config.BucketName, files, opts.Existing, result, p.config.Prune)
+		if err != nil {
+			return nil, err
+		}
+
+		changed = append(changed, pruned...)
+	}
+
+	// far more keys than are ever invalidated one by one
+	if !known || len(changed) > MaxChangedKeys {
+		changed = nil
+		known = false
+	}
 
 	return &Deployment{
-		Bucket: p.config.BucketName,
-		Region: p.config.Region,
+		Bucket:           p.config.BucketName,
+		Region:           p.config.Region,
+		Access:           p.config.Access,
+		ChangedKeys:      changed,
+		ChangedKeysKnown: known,
+		Prefix:           prefix,
 	}, nil
 }
 
+// pruneObjects deletes the objects left over from previous builds and returns
+// their keys. It is skipped when the upload was incomplete or the build is
+// empty so a failed deploy can never wipe the bucket. remote may be nil if it
+// was not listed yet.
+func pruneObjects(
+	ctx context.Context,
+	u terminal.Status,
+	client S3BucketAPI,
+	bucket string,
+	files []LocalFile,
+	remote map[string]RemoteObject,
+	result *UploadResult,
+	cfg *PruneConfig,
+) ([]string, error) {
+	if len(result.Failed) > 0 || len(files) == 0 {
+		u.Step(terminal.StatusWarn, "Skipping prune of stale objects, the build was not fully uploaded")
+		return nil, nil
+	}
+
+	var err error
+	if remote == nil {
+		u.Update("Listing existing objects...")
+
+		remote, err = ListRemoteObjects(ctx, client, bucket, "")
+		if err != nil {
+			u.Step(terminal.StatusError, "Could not list objects in bucket "+bucket)
+			return nil, err
+		}
+	}
+
+	stale := StaleKeys(remote, files, cfg.Protected)
+	if len(stale) == 0 {
+		u.Step(terminal.StatusOK, "No stale objects to prune")
+		return nil, nil
+	}
+
+	if cfg.DryRun {
+		u.Step(terminal.StatusWarn, fmt.Sprintf(
+			"Dry run, %d stale object(s) would be deleted:\n%v",
+			len(stale),
+			strings.Join(stale, "\n"),
+		))
+		return nil, nil
+	}
+
+	u.Update(fmt.Sprintf("Deleting %d stale object(s)...", len(stale)))
+
+	err = DeleteKeys(ctx, client, bucket, stale)
+	if err != nil {
+		u.Step(terminal.StatusError, "Could not prune stale objects")
+		return nil, err
+	}
+
+	u.Step(terminal.StatusOK, fmt.Sprintf("Pruned %d stale object(s)", len(stale)))
+
+	return stale, nil
+}
+
+func getDistributionPolicy(b string, distributionArns []string) string {
+	return fmt.Sprintf(`{
+		"Version":"2012-10-17",
+		"Statement":[
+			{
+				"Sid":"AllowCloudFrontServicePrincipal",
+				"Effect":"Allow",
+				"Principal":{"Service":"cloudfront.amazonaws.com"},
+				"Action":"s3:GetObject",
+				"Resource":["arn:aws:s3:::%s/*"],
+				"Condition":{"StringEquals":{"AWS:SourceArn":["%s"]}}
+			}
+		]
+	}`, b, strings.Join(distributionArns, `","`))
+}
+
 func getPolicy(b string) string {
 	return fmt.Sprintf(`{
 		"Version":"2012-10-17",
@@ -323,14 +684,3 @@ func getPolicy(b string) string {
 		]
 	}`, b)
 }
-
-func DetectMimeType(fname string, buffer []byte) string {
-	if strings.HasSuffix(fname, ".css") {
-		return "text/css"
-	} else if strings.HasSuffix(fname, ".js") {
-		return "application/javascript"
-	} else if strings.HasSuffix(fname, ".map") {
-		return "binary/octet-stream"
-	}
-	return mimetype.Detect(buffer).String()
-}
@@ -0,0 +1,216 @@
+package platform_test
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/hashicorp/waypoint-plugin-sdk/component"
+	"github.com/hashicorp/waypoint-plugin-sdk/terminal"
+	"github.com/pilot-framework/aws-cloudfront-waypoint-plugin/fakes"
+	"github.com/pilot-framework/aws-cloudfront-waypoint-plugin/platform"
+)
+
+func newPlatform(t *testing.T, config platform.PlatformConfig) *platform.Platform {
+	t.Helper()
+
+	p := &platform.Platform{}
+	c, err := p.Config()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	*c.(*platform.PlatformConfig) = config
+	if err := p.ConfigSet(c); err != nil {
+		t.Fatal(err)
+	}
+
+	return p
+}
+
+func TestDeployAndDestroy(t *testing.T) {
+	ctx := context.Background()
+	ui := terminal.ConsoleUI(ctx)
+	dir := writeFiles(t, map[string]string{
+		"index.html": "<html></html>",
+		"app.js":     "v1",
+	})
+
+	client := fakes.NewS3()
+	p := newPlatform(t, platform.PlatformConfig{
+		Region:     "us-east-1",
+		BucketName: "site",
+		BuildDir:   dir,
+		Access:     platform.AccessOAC,
+		Sync:       true,
+	})
+	src := &component.Source{App: "web", Path: dir}
+
+	d, err := p.Deploy(ctx, ui, src, &component.DeploymentConfig{Id: "01A"}, client)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if d.Bucket != "site" || d.Access != platform.AccessOAC || d.Prefix != "" {
+		t.Errorf("deployment = %+v", d)
+	}
+	if !d.ChangedKeysKnown || !reflect.DeepEqual(d.ChangedKeys, []string{"app.js", "index.html"}) {
+		t.Errorf("ChangedKeys = %v, known %v", d.ChangedKeys, d.ChangedKeysKnown)
+	}
+	if block := client.Bucket("site").PublicAccessBlock; block == nil || !block.BlockPublicPolicy {
+		t.Error("public access is not blocked")
+	}
+
+	// nothing changed, the release has nothing to invalidate
+	d, err = p.Deploy(ctx, ui, src, &component.DeploymentConfig{Id: "01B"}, client)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !d.ChangedKeysKnown || len(d.ChangedKeys) != 0 {
+		t.Errorf("unchanged deploy ChangedKeys = %v, known %v", d.ChangedKeys, d.ChangedKeysKnown)
+	}
+
+	if err := p.Destroy(ctx, ui, d, client); err != nil {
+		t.Fatal(err)
+	}
+	if client.Bucket("site") != nil {
+		t.Error("bucket was not deleted")
+	}
+
+	// the workspace bucket is already gone
+	if err := p.DestroyWorkspace(ctx, ui, client); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestImmutableDeploy(t *testing.T) {
+	ctx := context.Background()
+	ui := terminal.ConsoleUI(ctx)
+	dir := writeFiles(t, map[string]string{
+		"index.html": "<html></html>",
+	})
+
+	client := fakes.NewS3()
+	p := newPlatform(t, platform.PlatformConfig{
+		Region:     "us-east-1",
+		BucketName: "site",
+		BuildDir:   dir,
+		Access:     platform.AccessOAC,
+		Immutable:  true,
+	})
+	src := &component.Source{App: "web", Path: dir}
+
+	deployments := map[string]*platform.Deployment{}
+	for _, id := range []string{"01A", "01B"} {
+		d, err := p.Deploy(ctx, ui, src, &component.DeploymentConfig{Id: id}, client)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if d.Prefix != platform.DeploymentPrefix(id) || d.ChangedKeysKnown {
+			t.Errorf("deployment %v = %+v", id, d)
+		}
+		deployments[id] = d
+	}
+
+	want := []string{"deployments/01A/index.html", "deployments/01B/index.html"}
+	if keys := client.Keys("site"); !reflect.DeepEqual(keys, want) {
+		t.Errorf("bucket keys = %v, want %v", keys, want)
+	}
+
+	// only the destroyed deployment is deleted
+	if err := p.Destroy(ctx, ui, deployments["01A"], client); err != nil {
+		t.Fatal(err)
+	}
+
+	want = []string{"deployments/01B/index.html"}
+	if keys := client.Keys("site"); !reflect.DeepEqual(keys, want) {
+		t.Errorf("bucket keys = %v, want %v", keys, want)
+	}
+}
+
+func TestImmutableDeployRetention(t *testing.T) {
+	ctx := context.Background()
+	ui := terminal.ConsoleUI(ctx)
+	dir := writeFiles(t, map[string]string{
+		"index.html": "<html></html>",
+	})
+
+	client := fakes.NewS3()
+	p := newPlatform(t, platform.PlatformConfig{
+		Region:     "us-east-1",
+		BucketName: "site",
+		BuildDir:   dir,
+		Access:     platform.AccessOAC,
+		Immutable:  true,
+		Retain:     1,
+	})
+	src := &component.Source{App: "web", Path: dir}
+
+	deployments := map[string]*platform.Deployment{}
+	for _, id := range []string{"01A", "01B", "01C", "01D"} {
+		d, err := p.Deploy(ctx, ui, src, &component.DeploymentConfig{Id: id}, client)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if d.Prefix != platform.DeploymentPrefix(id) || d.ChangedKeysKnown {
+			t.Errorf("deployment %v = %+v", id, d)
+		}
+		deployments[id] = d
+	}
+
+	if err := platform.MarkReleased(ctx, client, "site", deployments["01A"].Prefix, ""); err != nil {
+		t.Fatal(err)
+	}
+
+	assertDeployments := func(want ...string) {
+		t.Helper()
+
+		got, err := platform.ListDeploymentPrefixes(ctx, client, "site")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("deployments = %v, want %v", got, want)
+		}
+	}
+
+	// 01B is the newest destroyed deployment and kept for rollbacks
+	if err := p.Destroy(ctx, ui, deployments["01B"], client); err != nil {
+		t.Fatal(err)
+	}
+	assertDeployments("deployments/01A/", "deployments/01B/", "deployments/01C/", "deployments/01D/")
+
+	// 01A is released and kept, 01B is no longer the newest destroyed one
+	for _, id := range []string{"01A", "01C"} {
+		if err := p.Destroy(ctx, ui, deployments[id], client); err != nil {
+			t.Fatal(err)
+		}
+	}
+	assertDeployments("deployments/01A/", "deployments/01C/", "deployments/01D/")
+
+	destroyed, err := platform.DestroyedPrefixes(ctx, client, "site")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"deployments/01A/", "deployments/01C/"}; !reflect.DeepEqual(destroyed, want) {
+		t.Errorf("destroyed = %v, want %v", destroyed, want)
+	}
+
+	// once no longer released, the destroyed 01A is deleted by the next destroy
+	if err := platform.MarkReleased(ctx, client, "site", deployments["01D"].Prefix, ""); err != nil {
+		t.Fatal(err)
+	}
+	if err := p.Destroy(ctx, ui, deployments["01C"], client); err != nil {
+		t.Fatal(err)
+	}
+	assertDeployments("deployments/01C/", "deployments/01D/")
+
+	if err := p.DestroyWorkspace(ctx, ui, client); err != nil {
+		t.Fatal(err)
+	}
+	if client.Bucket("site") != nil {
+		t.Error("workspace bucket was not deleted")
+	}
+}
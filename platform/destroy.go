@@ -3,11 +3,13 @@ package platform
 import (
 	"context"
 	"fmt"
+	"strings"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
-	"github.com/aws/aws-sdk-go-v2/config"
 	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
 	"github.com/hashicorp/waypoint-plugin-sdk/terminal"
+	"github.com/pilot-framework/aws-cloudfront-waypoint-plugin/awsclient"
 )
 
 // Implement the Destroyer interface
@@ -15,21 +17,62 @@ func (p *Platform) DestroyFunc() interface{} {
 	return p.destroy
 }
 
-func EmptyBucket(c context.Context, client *s3.Client, bucket string) error {
-	items, err := ListItems(c, client, &s3.ListObjectsV2Input{
+func GetVersioning(c context.Context, api S3BucketAPI, input *s3.GetBucketVersioningInput) (*s3.GetBucketVersioningOutput, error) {
+	return api.GetBucketVersioning(c, input)
+}
+
+func ListItemVersions(c context.Context, api S3BucketAPI, input *s3.ListObjectVersionsInput) (*s3.ListObjectVersionsOutput, error) {
+	return api.ListObjectVersions(c, input)
+}
+
+// EmptyBucket deletes every object in the bucket so that it can be removed.
+// If versioning was ever enabled on the bucket, all object versions and
+// delete markers are purged as well.
+func EmptyBucket(c context.Context, api S3BucketAPI, bucket string) error {
+	return DeletePrefix(c, api, bucket, "")
+}
+
+// DeletePrefix deletes every object whose key starts with prefix, including
+// all versions and delete markers if versioning was ever enabled.
+func DeletePrefix(c context.Context, api S3BucketAPI, bucket string, prefix string) error {
+	versioning, err := GetVersioning(c, api, &s3.GetBucketVersioningInput{
 		Bucket: aws.String(bucket),
 	})
-
 	if err != nil {
 		return err
 	}
 
-	for _, item := range items.Contents {
-		_, err := DeleteItem(c, client, &s3.DeleteObjectInput{
-			Bucket: aws.String(bucket),
-			Key:    item.Key,
-		})
+	// a bucket that never had versioning enabled reports no status
+	if versioning.Status == "" {
+		return deleteAllObjects(c, api, bucket, prefix)
+	}
+
+	return deleteAllVersions(c, api, bucket, prefix)
+}
 
+func deleteAllObjects(c context.Context, api S3BucketAPI, bucket string, prefix string) error {
+	input := &s3.ListObjectsV2Input{
+		Bucket: aws.String(bucket),
+	}
+
+	if prefix != "" {
+		input.Prefix = aws.String(prefix)
+	}
+
+	paginator := s3.NewListObjectsV2Paginator(api, input)
+
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(c)
+		if err != nil {
+			return err
+		}
+
+		objects := make([]types.ObjectIdentifier, len(page.Contents))
+		for i, item := range page.Contents {
+			objects[i] = types.ObjectIdentifier{Key: item.Key}
+		}
+
+		err = DeleteObjectBatch(c, api, bucket, objects)
 		if err != nil {
 			return err
 		}
@@ -38,23 +81,152 @@ func EmptyBucket(c context.Context, client *s3.Client, bucket string) error {
 	return nil
 }
 
+func deleteAllVersions(c context.Context, api S3BucketAPI, bucket string, prefix string) error {
+	input := &s3.ListObjectVersionsInput{
+		Bucket: aws.String(bucket),
+	}
+
+	if prefix != "" {
+		input.Prefix = aws.String(prefix)
+	}
+
+	for {
+		page, err := ListItemVersions(c, api, input)
+		if err != nil {
+			return err
+		}
+
+		objects := []types.ObjectIdentifier{}
+		for _, v := range page.Versions {
+			objects = append(objects, types.ObjectIdentifier{Key: v.Key, VersionId: v.VersionId})
+		}
+		for _, m := range page.DeleteMarkers {
+			objects = append(objects, types.ObjectIdentifier{Key: m.Key, VersionId: m.VersionId})
+		}
+
+		err = DeleteObjectBatch(c, api, bucket, objects)
+		if err != nil {
+			return err
+		}
+
+		if !page.IsTruncated {
+			return nil
+		}
+
+		input.KeyMarker = page.NextKeyMarker
+		input.VersionIdMarker = page.NextVersionIdMarker
+	}
+}
+
 // If an error is returned, Waypoint stops the execution flow and
 // returns an error to the user.
 func (p *Platform) destroy(ctx context.Context, ui terminal.UI, deployment *Deployment) error {
+	// the bucket is where it was deployed to, even if the region changed since
+	region := deployment.Region
+	if region == "" {
+		region = p.config.Region
+	}
+
+	clients, err := awsclient.New(ctx, clientSettings(&p.config, region))
+	if err != nil {
+		ui.Output("AWS configuration error, "+err.Error(), terminal.WithErrorStyle())
+		return err
+	}
+
+	return p.Destroy(ctx, ui, deployment, clients.S3())
+}
+
+// Destroy empties and deletes the bucket through client. A deployment that
+// was uploaded to its own prefix only deletes deployment prefixes, the
+// bucket keeps serving the released one.
+func (p *Platform) Destroy(ctx context.Context, ui terminal.UI, deployment *Deployment, client S3BucketAPI) error {
 	u := ui.Status()
 	defer u.Close()
 
-	cfg, err := config.LoadDefaultConfig(ctx, config.WithRegion(p.config.Region))
+	if deployment.Prefix != "" {
+		return p.collectDeployments(ctx, u, deployment, client)
+	}
+
+	return p.deleteBucket(ctx, u, p.config.BucketName, client)
+}
+
+// collectDeployments deletes the prefix of the destroyed deployment unless
+// it is still released. With retention, destroyed deployments are kept
+// until more than the retained number were destroyed after them. Only
+// destroyed deployments recorded under DestroyedKey are deleted, never one
+// Waypoint still tracks.
+func (p *Platform) collectDeployments(ctx context.Context, u terminal.Status, deployment *Deployment, client S3BucketAPI) error {
+	bucket := deployment.Bucket
+
+	released, canary, err := ReleasedPrefix(ctx, client, bucket)
+	if err != nil {
+		u.Step(terminal.StatusError, "Could not find the released deployment in "+bucket)
+		return err
+	}
+
+	destroyed, err := DestroyedPrefixes(ctx, client, bucket)
+	if err != nil {
+		u.Step(terminal.StatusError, "Could not read the destroyed deployments of "+bucket)
+		return err
+	}
+
+	if !contains(destroyed, deployment.Prefix) {
+		destroyed = append(destroyed, deployment.Prefix)
+	}
+
+	// recorded first, so that a deployment left behind by a failure below is
+	// deleted by a later destroy
+	err = RecordDestroyed(ctx, client, bucket, destroyed)
+	if err != nil {
+		u.Step(terminal.StatusError, "Could not record the destroyed deployments of "+bucket)
+		return err
+	}
+
+	expired := ExpiredPrefixes(destroyed, p.config.Retain, released, canary)
+	kept := true
+
+	for _, prefix := range expired {
+		if prefix == deployment.Prefix {
+			kept = false
+		}
+
+		u.Update("Deleting objects under " + prefix + "...")
+
+		err = DeletePrefix(ctx, client, bucket, prefix)
+		if err != nil {
+			u.Step(terminal.StatusError, "Could not delete deployment "+prefix)
+			return err
+		}
+
+		u.Step(terminal.StatusOK, fmt.Sprintf("Deleted deployment %v from S3 bucket %v", prefix, bucket))
+	}
+
+	remaining := []string{}
+	for _, prefix := range destroyed {
+		if !contains(expired, prefix) {
+			remaining = append(remaining, prefix)
+		}
+	}
+
+	err = RecordDestroyed(ctx, client, bucket, remaining)
 	if err != nil {
-		u.Step(terminal.StatusError, "AWS configuration error, "+err.Error())
+		u.Step(terminal.StatusError, "Could not record the destroyed deployments of "+bucket)
 		return err
 	}
 
-	client := s3.NewFromConfig(cfg)
+	if deployment.Prefix == released || deployment.Prefix == canary {
+		u.Step(terminal.StatusWarn, fmt.Sprintf("Kept deployment %v, it is still released", deployment.Prefix))
+	} else if kept {
+		u.Step(terminal.StatusOK, fmt.Sprintf("Kept deployment %v for rollbacks", deployment.Prefix))
+	}
 
+	return nil
+}
+
+func (p *Platform) deleteBucket(ctx context.Context, u terminal.Status, bucket string, client S3BucketAPI) error {
 	u.Update("Deleting objects...")
 
-	err = EmptyBucket(ctx, client, p.config.BucketName)
+	err := EmptyBucket(ctx, client, bucket)
 	if err != nil {
 		return err
 	}
@@ -62,13 +234,50 @@ func (p *Platform) destroy(ctx context.Context, ui terminal.UI, deployment *Depl
 	u.Update("Deleting bucket...")
 
 	_, err = DeleteBucket(ctx, client, &s3.DeleteBucketInput{
-		Bucket: aws.String(p.config.BucketName),
+		Bucket: aws.String(bucket),
 	})
 	if err != nil {
 		return err
 	}
 
-	u.Step(terminal.StatusOK, fmt.Sprintf("Deleted S3 bucket %v", p.config.BucketName))
+	u.Step(terminal.StatusOK, fmt.Sprintf("Deleted S3 bucket %v", bucket))
 
 	return nil
 }
+
+// Implement the WorkspaceDestroyer interface
+func (p *Platform) DestroyWorkspaceFunc() interface{} {
+	return p.destroyWorkspace
+}
+
+// destroyWorkspace deletes the bucket along with any retained deployments
+// once Waypoint destroyed all of them.
+func (p *Platform) destroyWorkspace(ctx context.Context, ui terminal.UI) error {
+	clients, err := awsclient.New(ctx, clientSettings(&p.config, p.config.Region))
+	if err != nil {
+		ui.Output("AWS configuration error, "+err.Error(), terminal.WithErrorStyle())
+		return err
+	}
+
+	return p.DestroyWorkspace(ctx, ui, clients.S3())
+}
+
+// DestroyWorkspace empties and deletes the bucket through client. It does
+// nothing if the bucket is already gone.
+func (p *Platform) DestroyWorkspace(ctx context.Context, ui terminal.UI, client S3BucketAPI) error {
+	u := ui.Status()
+	defer u.Close()
+
+	err := p.deleteBucket(ctx, u, p.config.BucketName, client)
+	if err != nil && BucketNotFound(err) {
+		u.Step(terminal.StatusOK, fmt.Sprintf("S3 bucket %v is already deleted", p.config.BucketName))
+		return nil
+	}
+
+	return err
+}
+
+// BucketNotFound reports whether err is S3 telling that the bucket does not exist
+func BucketNotFound(err error) bool {
+	return strings.Contains(err.Error(), "NoSuchBucket")
+}
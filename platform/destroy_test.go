@@ -0,0 +1,176 @@
+package platform_test
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"github.com/hashicorp/waypoint-plugin-sdk/terminal"
+	"github.com/pilot-framework/aws-cloudfront-waypoint-plugin/fakes"
+	"github.com/pilot-framework/aws-cloudfront-waypoint-plugin/platform"
+)
+
+// versionLister counts the version listings
+type versionLister struct {
+	*fakes.S3
+	pages int
+}
+
+func (v *versionLister) ListObjectVersions(ctx context.Context, input *s3.ListObjectVersionsInput, optFns ...func(*s3.Options)) (*s3.ListObjectVersionsOutput, error) {
+	v.pages++
+
+	return v.S3.ListObjectVersions(ctx, input, optFns...)
+}
+
+// versions returns the number of versions and delete markers under prefix
+func versions(t *testing.T, client *fakes.S3, bucket string, prefix string) (int, int) {
+	t.Helper()
+
+	n, markers := 0, 0
+	input := &s3.ListObjectVersionsInput{
+		Bucket: aws.String(bucket),
+		Prefix: aws.String(prefix),
+	}
+
+	for {
+		out, err := client.ListObjectVersions(context.Background(), input)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		n += len(out.Versions)
+		markers += len(out.DeleteMarkers)
+
+		if !out.IsTruncated {
+			return n, markers
+		}
+
+		input.KeyMarker = out.NextKeyMarker
+		input.VersionIdMarker = out.NextVersionIdMarker
+	}
+}
+
+func TestDestroyVersionedBucket(t *testing.T) {
+	ctx := context.Background()
+	client := &versionLister{S3: fakes.NewS3()}
+	newBucket(t, client.S3, "site")
+
+	// objects put before versioning keep the null version
+	putObject(t, client, "site", "index.html")
+
+	_, err := client.PutBucketVersioning(ctx, &s3.PutBucketVersioningInput{
+		Bucket:                  aws.String("site"),
+		VersioningConfiguration: &types.VersioningConfiguration{Status: types.BucketVersioningStatusEnabled},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// two versions of every key, every third one deleted since
+	keys := []string{}
+	for i := 0; i < 600; i++ {
+		key := fmt.Sprintf("assets/file-%04d.js", i)
+		putObject(t, client, "site", key)
+		putObject(t, client, "site", key)
+		keys = append(keys, key)
+	}
+	putObject(t, client, "site", "index.html")
+
+	for i := 0; i < len(keys); i += 3 {
+		_, err := client.DeleteObject(ctx, &s3.DeleteObjectInput{Bucket: aws.String("site"), Key: aws.String(keys[i])})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if n, markers := versions(t, client.S3, "site", ""); n != 1202 || markers != 200 {
+		t.Fatalf("%d versions and %d delete markers, want 1202 and 200", n, markers)
+	}
+
+	// the bucket cannot be deleted while versions are left
+	_, err = client.DeleteBucket(ctx, &s3.DeleteBucketInput{Bucket: aws.String("site")})
+	if err == nil {
+		t.Fatal("deleted a bucket with versions left")
+	}
+
+	// only the versions under the prefix are deleted
+	if err := platform.DeletePrefix(ctx, client, "site", "assets/file-00"); err != nil {
+		t.Fatal(err)
+	}
+	if n, markers := versions(t, client.S3, "site", "assets/file-00"); n != 0 || markers != 0 {
+		t.Errorf("%d versions and %d delete markers left under the prefix", n, markers)
+	}
+	if n, _ := versions(t, client.S3, "site", "index.html"); n != 2 {
+		t.Errorf("%d versions of index.html, want 2", n)
+	}
+
+	client.pages = 0
+
+	p := newPlatform(t, platform.PlatformConfig{Region: "us-east-1", BucketName: "site", BuildDir: t.TempDir()})
+	if err := p.Destroy(ctx, terminal.ConsoleUI(ctx), &platform.Deployment{Bucket: "site"}, client); err != nil {
+		t.Fatal(err)
+	}
+
+	if client.Bucket("site") != nil {
+		t.Error("bucket was not deleted")
+	}
+	if client.pages < 2 {
+		t.Errorf("listed %d page(s) of versions, want more than one", client.pages)
+	}
+}
+
+func TestListObjectVersionsPaging(t *testing.T) {
+	ctx := context.Background()
+	client := fakes.NewS3()
+	newBucket(t, client, "site")
+
+	_, err := client.PutBucketVersioning(ctx, &s3.PutBucketVersioningInput{
+		Bucket:                  aws.String("site"),
+		VersioningConfiguration: &types.VersioningConfiguration{Status: types.BucketVersioningStatusEnabled},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, key := range []string{"a", "a", "b"} {
+		putObject(t, client, "site", key)
+	}
+	if _, err := client.DeleteObject(ctx, &s3.DeleteObjectInput{Bucket: aws.String("site"), Key: aws.String("b")}); err != nil {
+		t.Fatal(err)
+	}
+
+	listed := []string{}
+	input := &s3.ListObjectVersionsInput{Bucket: aws.String("site"), MaxKeys: 1}
+
+	for {
+		out, err := client.ListObjectVersions(ctx, input)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		for _, v := range out.Versions {
+			listed = append(listed, aws.ToString(v.Key))
+		}
+		for _, m := range out.DeleteMarkers {
+			listed = append(listed, aws.ToString(m.Key)+" deleted")
+		}
+
+		if !out.IsTruncated {
+			break
+		}
+
+		input.KeyMarker = out.NextKeyMarker
+		input.VersionIdMarker = out.NextVersionIdMarker
+	}
+
+	if want := []string{"a", "a", "b deleted", "b"}; !reflect.DeepEqual(listed, want) {
+		t.Errorf("listed %v, want %v", listed, want)
+	}
+	if keys := client.Keys("site"); !reflect.DeepEqual(keys, []string{"a"}) {
+		t.Errorf("current keys = %v, want a", keys)
+	}
+}
@@ -0,0 +1,87 @@
+package platform
+
+import (
+	"fmt"
+	"path"
+	"strings"
+
+	"github.com/gabriel-vasile/mimetype"
+)
+
+// contentTypes maps file extensions of common web assets to their MIME type.
+// Content sniffing cannot tell these apart reliably, e.g. svg and json are
+// detected as plain text and source maps as binary.
+var contentTypes = map[string]string{
+	".html":        "text/html; charset=utf-8",
+	".htm":         "text/html; charset=utf-8",
+	".css":         "text/css",
+	".js":          "application/javascript",
+	".mjs":         "application/javascript",
+	".cjs":         "application/javascript",
+	".map":         "application/json",
+	".json":        "application/json",
+	".webmanifest": "application/manifest+json",
+	".xml":         "application/xml",
+	".txt":         "text/plain; charset=utf-8",
+	".csv":         "text/csv",
+	".md":          "text/markdown",
+	".svg":         "image/svg+xml",
+	".png":         "image/png",
+	".jpg":         "image/jpeg",
+	".jpeg":        "image/jpeg",
+	".gif":         "image/gif",
+	".webp":        "image/webp",
+	".avif":        "image/avif",
+	".ico":         "image/x-icon",
+	".woff":        "font/woff",
+	".woff2":       "font/woff2",
+	".ttf":         "font/ttf",
+	".otf":         "font/otf",
+	".eot":         "application/vnd.ms-fontobject",
+	".wasm":        "application/wasm",
+	".pdf":         "application/pdf",
+	".mp4":         "video/mp4",
+	".webm":        "video/webm",
+	".mp3":         "audio/mpeg",
+	".ogg":         "audio/ogg",
+	".wav":         "audio/wav",
+}
+
+// normalizeContentTypes validates the content_types overrides of a
+// PlatformConfig and returns them keyed by lowercase extension with a
+// leading dot, so both "svg" and ".SVG" are accepted.
+func normalizeContentTypes(overrides map[string]string) (map[string]string, error) {
+	normalized := make(map[string]string, len(overrides))
+
+	for ext, contentType := range overrides {
+		if contentType == "" {
+			return nil, fmt.Errorf("content_types entry for %q must not be empty", ext)
+		}
+
+		ext = strings.ToLower(ext)
+		if !strings.HasPrefix(ext, ".") {
+			ext = "." + ext
+		}
+
+		normalized[ext] = contentType
+	}
+
+	return normalized, nil
+}
+
+// DetectMimeType resolves the MIME type of a file. The extension is looked
+// up in overrides first, then in the built-in table, and only if neither
+// knows it the content is sniffed. overrides must be normalized and may be nil.
+func DetectMimeType(fname string, buffer []byte, overrides map[string]string) string {
+	ext := strings.ToLower(path.Ext(fname))
+
+	if contentType, ok := overrides[ext]; ok {
+		return contentType
+	}
+
+	if contentType, ok := contentTypes[ext]; ok {
+		return contentType
+	}
+
+	return mimetype.Detect(buffer).String()
+}
@@ -0,0 +1,69 @@
+package platform
+
+import "testing"
+
+var pngHeader = []byte("\x89PNG\r\n\x1a\n\x00\x00\x00\rIHDR")
+
+func TestDetectMimeType(t *testing.T) {
+	overrides := map[string]string{
+		".js":   "text/javascript",
+		".data": "application/x-custom",
+	}
+
+	tests := []struct {
+		desc      string
+		fname     string
+		buffer    []byte
+		overrides map[string]string
+		want      string
+	}{
+		{"override beats built-in table", "app.js", nil, overrides, "text/javascript"},
+		{"override for unknown extension", "dump.data", pngHeader, overrides, "application/x-custom"},
+		{"built-in table without overrides", "app.js", nil, nil, "application/javascript"},
+		{"built-in table beats sniffing", "app.js", pngHeader, nil, "application/javascript"},
+		{"built-in table for svg", "logo.svg", []byte("<svg></svg>"), nil, "image/svg+xml"},
+		{"built-in table for source maps", "app.js.map", []byte(`{"version":3}`), nil, "application/json"},
+		{"case-insensitive extension", "LOGO.SVG", nil, nil, "image/svg+xml"},
+		{"case-insensitive override", "APP.JS", nil, overrides, "text/javascript"},
+		{"sniffs unknown extension", "image.bin", pngHeader, nil, "image/png"},
+		{"sniffs missing extension", "LICENSE", []byte("plain text"), nil, "text/plain; charset=utf-8"},
+	}
+
+	for _, tt := range tests {
+		if got := DetectMimeType(tt.fname, tt.buffer, tt.overrides); got != tt.want {
+			t.Errorf("%v: DetectMimeType(%q) = %q, want %q", tt.desc, tt.fname, got, tt.want)
+		}
+	}
+}
+
+func TestNormalizeContentTypes(t *testing.T) {
+	got, err := normalizeContentTypes(map[string]string{
+		"svg":   "image/svg+xml; charset=utf-8",
+		".WASM": "application/wasm",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := map[string]string{
+		".svg":  "image/svg+xml; charset=utf-8",
+		".wasm": "application/wasm",
+	}
+	if len(got) != len(want) {
+		t.Fatalf("normalizeContentTypes() = %v, want %v", got, want)
+	}
+	for ext, contentType := range want {
+		if got[ext] != contentType {
+			t.Errorf("normalizeContentTypes()[%q] = %q, want %q", ext, got[ext], contentType)
+		}
+	}
+
+	if got := DetectMimeType("icon.SVG", nil, got); got != "image/svg+xml; charset=utf-8" {
+		t.Errorf("DetectMimeType with normalized override = %q", got)
+	}
+
+	_, err = normalizeContentTypes(map[string]string{".svg": ""})
+	if err == nil {
+		t.Error("normalizeContentTypes accepted an empty content type")
+	}
+}
@@ -0,0 +1,148 @@
+package platform
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+)
+
+const (
+	// DefaultMultipartThreshold is the body size from which multipart
+	// upload is used when none is configured.
+	DefaultMultipartThreshold = 64 << 20
+
+	// MaxMultipartThreshold is the largest body S3 accepts in a single PUT
+	MaxMultipartThreshold = 5 << 30
+
+	// S3 allows at most 10000 parts per upload
+	maxParts = 10000
+
+	defaultPartSize = 16 << 20
+	partAttempts    = 3
+)
+
+func CreateUpload(c context.Context, api S3BucketAPI, input *s3.CreateMultipartUploadInput) (*s3.CreateMultipartUploadOutput, error) {
+	return api.CreateMultipartUpload(c, input)
+}
+
+func AddPart(c context.Context, api S3BucketAPI, input *s3.UploadPartInput) (*s3.UploadPartOutput, error) {
+	return api.UploadPart(c, input)
+}
+
+func CompleteUpload(c context.Context, api S3BucketAPI, input *s3.CompleteMultipartUploadInput) (*s3.CompleteMultipartUploadOutput, error) {
+	return api.CompleteMultipartUpload(c, input)
+}
+
+func AbortUpload(c context.Context, api S3BucketAPI, input *s3.AbortMultipartUploadInput) (*s3.AbortMultipartUploadOutput, error) {
+	return api.AbortMultipartUpload(c, input)
+}
+
+// partSize picks the smallest part size, starting from defaultPartSize,
+// that fits an object of the given size into maxParts parts.
+func partSize(size int64) int64 {
+	ps := int64(defaultPartSize)
+	for (size+ps-1)/ps > maxParts {
+		ps *= 2
+	}
+
+	return ps
+}
+
+// putMultipart streams body to S3 as a multipart upload using the bucket,
+// key and headers of input. Every part is retried on failure and the upload
+// is aborted if it cannot be completed so no orphaned parts are left behind.
+func putMultipart(c context.Context, api S3BucketAPI, input *s3.PutObjectInput, body io.ReaderAt, size int64) error {
+	created, err := CreateUpload(c, api, &s3.CreateMultipartUploadInput{
+		Bucket:          input.Bucket,
+		Key:             input.Key,
+		CacheControl:    input.CacheControl,
+		ContentEncoding: input.ContentEncoding,
+		ContentType:     input.ContentType,
+		Metadata:        input.Metadata,
+	})
+	if err != nil {
+		return err
+	}
+
+	ps := partSize(size)
+	parts := []types.CompletedPart{}
+
+	for num, offset := int32(1), int64(0); offset < size; num, offset = num+1, offset+ps {
+		n := ps
+		if size-offset < n {
+			n = size - offset
+		}
+
+		etag, err := uploadPart(c, api, input, created.UploadId, num, io.NewSectionReader(body, offset, n))
+		if err != nil {
+			return abortMultipart(api, input, created.UploadId, fmt.Errorf("part %d: %w", num, err))
+		}
+
+		parts = append(parts, types.CompletedPart{ETag: etag, PartNumber: num})
+	}
+
+	_, err = CompleteUpload(c, api, &s3.CompleteMultipartUploadInput{
+		Bucket:          input.Bucket,
+		Key:             input.Key,
+		UploadId:        created.UploadId,
+		MultipartUpload: &types.CompletedMultipartUpload{Parts: parts},
+	})
+	if err != nil {
+		return abortMultipart(api, input, created.UploadId, err)
+	}
+
+	return nil
+}
+
+func uploadPart(
+	c context.Context,
+	api S3BucketAPI,
+	input *s3.PutObjectInput,
+	uploadId *string,
+	num int32,
+	part *io.SectionReader,
+) (*string, error) {
+	for attempt := 1; ; attempt++ {
+		out, err := AddPart(c, api, &s3.UploadPartInput{
+			Bucket:        input.Bucket,
+			Key:           input.Key,
+			UploadId:      uploadId,
+			PartNumber:    num,
+			Body:          io.NewSectionReader(part, 0, part.Size()),
+			ContentLength: part.Size(),
+		})
+		if err == nil {
+			return out.ETag, nil
+		}
+
+		if attempt == partAttempts {
+			return nil, err
+		}
+
+		select {
+		case <-c.Done():
+			return nil, err
+		case <-time.After(time.Duration(attempt) * time.Second):
+		}
+	}
+}
+
+// abortMultipart discards the uploaded parts and returns cause. It does not use
+// the deploy context, which may be the reason the upload failed.
+func abortMultipart(api S3BucketAPI, input *s3.PutObjectInput, uploadId *string, cause error) error {
+	_, err := AbortUpload(context.Background(), api, &s3.AbortMultipartUploadInput{
+		Bucket:   input.Bucket,
+		Key:      input.Key,
+		UploadId: uploadId,
+	})
+	if err != nil {
+		return fmt.Errorf("%v, aborting multipart upload %v failed: %v", cause, aws.ToString(uploadId), err)
+	}
+
+	return cause
+}
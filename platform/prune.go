@@ -0,0 +1,106 @@
+package platform
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+)
+
+// MaxDeleteBatch is the maximum number of keys S3 accepts in a single
+// DeleteObjects call.
+const MaxDeleteBatch = 1000
+
+// PruneConfig enables deleting objects that are no longer part of the build.
+type PruneConfig struct {
+	// Key prefixes that are never deleted, e.g. assets shared with other sites
+	Protected []string `hcl:"protected,optional"`
+	// Only report what would be deleted
+	DryRun bool `hcl:"dry_run,optional"`
+}
+
+func DeleteItems(c context.Context, api S3BucketAPI, input *s3.DeleteObjectsInput) (*s3.DeleteObjectsOutput, error) {
+	return api.DeleteObjects(c, input)
+}
+
+// StaleKeys returns the sorted keys of remote objects that are not part of
+// the local build and are not under one of the protected prefixes. Immutable
+// deployments and their records under DeploymentsPrefix are always protected.
+func StaleKeys(remote map[string]RemoteObject, files []LocalFile, protected []string) []string {
+	local := make(map[string]bool, len(files))
+	for _, f := range files {
+		local[f.Key] = true
+	}
+
+	stale := []string{}
+
+	for key := range remote {
+		if local[key] || strings.HasPrefix(key, DeploymentsPrefix) || isProtected(key, protected) {
+			continue
+		}
+
+		stale = append(stale, key)
+	}
+
+	sort.Strings(stale)
+
+	return stale
+}
+
+func isProtected(key string, protected []string) bool {
+	for _, prefix := range protected {
+		if strings.HasPrefix(key, strings.TrimPrefix(prefix, "/")) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// DeleteObjectBatch deletes objects from the bucket, MaxDeleteBatch at a time.
+// Failures reported by S3 for individual keys are returned as a single error.
+func DeleteObjectBatch(c context.Context, api S3BucketAPI, bucket string, objects []types.ObjectIdentifier) error {
+	failed := []string{}
+
+	for start := 0; start < len(objects); start += MaxDeleteBatch {
+		end := start + MaxDeleteBatch
+		if end > len(objects) {
+			end = len(objects)
+		}
+
+		out, err := DeleteItems(c, api, &s3.DeleteObjectsInput{
+			Bucket: aws.String(bucket),
+			Delete: &types.Delete{
+				Objects: objects[start:end],
+				Quiet:   true,
+			},
+		})
+		if err != nil {
+			return err
+		}
+
+		for _, e := range out.Errors {
+			failed = append(failed, fmt.Sprintf("%v: %v", aws.ToString(e.Key), aws.ToString(e.Message)))
+		}
+	}
+
+	if len(failed) > 0 {
+		return fmt.Errorf("could not delete %d object(s): %v", len(failed), strings.Join(failed, "; "))
+	}
+
+	return nil
+}
+
+// DeleteKeys deletes the given keys from the bucket in batches.
+func DeleteKeys(c context.Context, api S3BucketAPI, bucket string, keys []string) error {
+	objects := make([]types.ObjectIdentifier, len(keys))
+	for i := range keys {
+		objects[i] = types.ObjectIdentifier{Key: aws.String(keys[i])}
+	}
+
+	return DeleteObjectBatch(c, api, bucket, objects)
+}
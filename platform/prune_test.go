@@ -0,0 +1,165 @@
+package platform_test
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/hashicorp/waypoint-plugin-sdk/component"
+	"github.com/hashicorp/waypoint-plugin-sdk/terminal"
+	"github.com/pilot-framework/aws-cloudfront-waypoint-plugin/fakes"
+	"github.com/pilot-framework/aws-cloudfront-waypoint-plugin/platform"
+)
+
+func putObject(t *testing.T, client platform.S3BucketAPI, bucket string, key string) {
+	t.Helper()
+
+	_, err := client.PutObject(context.Background(), &s3.PutObjectInput{
+		Bucket: aws.String(bucket),
+		Key:    aws.String(key),
+		Body:   strings.NewReader(key),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestStaleKeys(t *testing.T) {
+	remote := map[string]platform.RemoteObject{}
+	for _, key := range []string{
+		"index.html",
+		"old.js",
+		"shared/logo.png",
+		"keep.txt",
+		"deployments/01A/index.html",
+		"deployments/released",
+	} {
+		remote[key] = platform.RemoteObject{Key: key}
+	}
+
+	files := []platform.LocalFile{{Key: "index.html"}}
+
+	got := platform.StaleKeys(remote, files, []string{"/shared/", "keep"})
+	if want := []string{"old.js"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("StaleKeys = %v, want %v", got, want)
+	}
+
+	got = platform.StaleKeys(remote, files, nil)
+	if want := []string{"keep.txt", "old.js", "shared/logo.png"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("StaleKeys without protected prefixes = %v, want %v", got, want)
+	}
+}
+
+// batchCounter counts the DeleteObjects calls
+type batchCounter struct {
+	*fakes.S3
+	batches []int
+}
+
+func (b *batchCounter) DeleteObjects(ctx context.Context, input *s3.DeleteObjectsInput, optFns ...func(*s3.Options)) (*s3.DeleteObjectsOutput, error) {
+	b.batches = append(b.batches, len(input.Delete.Objects))
+
+	return b.S3.DeleteObjects(ctx, input, optFns...)
+}
+
+func TestDeleteKeysInBatches(t *testing.T) {
+	client := &batchCounter{S3: fakes.NewS3()}
+	newBucket(t, client.S3, "site")
+
+	keys := []string{}
+	for i := 0; i < 2500; i++ {
+		key := fmt.Sprintf("file-%04d.txt", i)
+		putObject(t, client, "site", key)
+		keys = append(keys, key)
+	}
+
+	if err := platform.DeleteKeys(context.Background(), client, "site", keys); err != nil {
+		t.Fatal(err)
+	}
+
+	if want := []int{1000, 1000, 500}; !reflect.DeepEqual(client.batches, want) {
+		t.Errorf("batches = %v, want %v", client.batches, want)
+	}
+	if left := client.Keys("site"); len(left) != 0 {
+		t.Errorf("%d keys left", len(left))
+	}
+}
+
+func TestDeployPrune(t *testing.T) {
+	tests := []struct {
+		name   string
+		prune  *platform.PruneConfig
+		fail   string
+		remain []string
+	}{
+		{
+			name:   "stale objects are deleted",
+			prune:  &platform.PruneConfig{Protected: []string{"shared/"}},
+			remain: []string{"app.js", "deployments/released", "index.html", "shared/logo.png"},
+		},
+		{
+			name:   "dry run",
+			prune:  &platform.PruneConfig{DryRun: true},
+			remain: []string{"app.js", "deployments/released", "index.html", "old.js", "shared/logo.png"},
+		},
+		{
+			name:   "incomplete upload",
+			prune:  &platform.PruneConfig{},
+			fail:   "app.js",
+			remain: []string{"deployments/released", "index.html", "old.js", "shared/logo.png"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			ui := terminal.ConsoleUI(ctx)
+			dir := writeFiles(t, map[string]string{
+				"index.html": "<html></html>",
+				"app.js":     "v1",
+			})
+
+			client := &failingS3{S3: fakes.NewS3(), fail: tt.fail}
+			newBucket(t, client.S3, "site")
+			for _, key := range []string{"old.js", "shared/logo.png", "deployments/released"} {
+				putObject(t, client.S3, "site", key)
+			}
+
+			p := newPlatform(t, platform.PlatformConfig{
+				Region:     "us-east-1",
+				BucketName: "site",
+				BuildDir:   dir,
+				Access:     platform.AccessOAC,
+				Prune:      tt.prune,
+			})
+
+			d, err := p.Deploy(ctx, ui, &component.Source{App: "web", Path: dir}, &component.DeploymentConfig{Id: "01A"}, client)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if keys := client.Keys("site"); !reflect.DeepEqual(keys, tt.remain) {
+				t.Errorf("bucket keys = %v, want %v", keys, tt.remain)
+			}
+
+			pruned := !contains(tt.remain, "old.js")
+			if contains(d.ChangedKeys, "old.js") != pruned {
+				t.Errorf("ChangedKeys = %v, pruned old.js %v", d.ChangedKeys, pruned)
+			}
+		})
+	}
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,202 @@
+package platform
+
+import (
+	"context"
+	"io"
+	"sort"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+)
+
+// ReleasedKey is the object recording which deployment prefixes the
+// distribution and its canary serve, so that they are never garbage
+// collected. It is not reachable through the distribution, whose origin
+// path is a deployment.
+const ReleasedKey = DeploymentsPrefix + "released"
+
+// User metadata keys of ReleasedKey holding the prefixes
+const (
+	ReleasedMetadataKey       = "pilot-prefix"
+	ReleasedCanaryMetadataKey = "pilot-canary-prefix"
+)
+
+// DestroyedKey is the object listing the prefixes of destroyed deployments
+// that are kept, one per line. Only these are garbage collected, a
+// deployment that was not destroyed is never deleted.
+const DestroyedKey = DeploymentsPrefix + "destroyed"
+
+// MarkReleased records prefix as the deployment the distribution serves and
+// canaryPrefix as the one of its canary, if any.
+func MarkReleased(c context.Context, api S3BucketAPI, bucket string, prefix string, canaryPrefix string) error {
+	metadata := map[string]string{ReleasedMetadataKey: prefix}
+	if canaryPrefix != "" {
+		metadata[ReleasedCanaryMetadataKey] = canaryPrefix
+	}
+
+	_, err := AddFile(c, api, &s3.PutObjectInput{
+		Bucket:   aws.String(bucket),
+		Key:      aws.String(ReleasedKey),
+		Body:     strings.NewReader(""),
+		Metadata: metadata,
+	})
+
+	return err
+}
+
+// ClearReleased removes the record of the released deployment.
+func ClearReleased(c context.Context, api S3BucketAPI, bucket string) error {
+	_, err := DeleteItem(c, api, &s3.DeleteObjectInput{
+		Bucket: aws.String(bucket),
+		Key:    aws.String(ReleasedKey),
+	})
+
+	return err
+}
+
+// ReleasedPrefix returns the deployment prefixes the distribution and its
+// canary serve, which are empty if none was released yet.
+func ReleasedPrefix(c context.Context, api S3BucketAPI, bucket string) (string, string, error) {
+	head, err := HeadItem(c, api, &s3.HeadObjectInput{
+		Bucket: aws.String(bucket),
+		Key:    aws.String(ReleasedKey),
+	})
+	if err != nil {
+		if ObjectNotFound(err) {
+			return "", "", nil
+		}
+
+		return "", "", err
+	}
+
+	return head.Metadata[ReleasedMetadataKey], head.Metadata[ReleasedCanaryMetadataKey], nil
+}
+
+// DestroyedPrefixes returns the prefixes of the destroyed deployments that
+// are still kept.
+func DestroyedPrefixes(c context.Context, api S3BucketAPI, bucket string) ([]string, error) {
+	out, err := GetItem(c, api, &s3.GetObjectInput{
+		Bucket: aws.String(bucket),
+		Key:    aws.String(DestroyedKey),
+	})
+	if err != nil {
+		if ObjectNotFound(err) {
+			return []string{}, nil
+		}
+
+		return nil, err
+	}
+	defer out.Body.Close()
+
+	data, err := io.ReadAll(out.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	return strings.Fields(string(data)), nil
+}
+
+// RecordDestroyed replaces the prefixes of the destroyed deployments that
+// are kept.
+func RecordDestroyed(c context.Context, api S3BucketAPI, bucket string, prefixes []string) error {
+	if len(prefixes) == 0 {
+		_, err := DeleteItem(c, api, &s3.DeleteObjectInput{
+			Bucket: aws.String(bucket),
+			Key:    aws.String(DestroyedKey),
+		})
+
+		return err
+	}
+
+	sorted := append([]string{}, prefixes...)
+	sort.Strings(sorted)
+
+	_, err := AddFile(c, api, &s3.PutObjectInput{
+		Bucket:      aws.String(bucket),
+		Key:         aws.String(DestroyedKey),
+		Body:        strings.NewReader(strings.Join(sorted, "\n") + "\n"),
+		ContentType: aws.String("text/plain"),
+	})
+
+	return err
+}
+
+// ObjectNotFound reports whether err is S3 telling that an object does not exist
+func ObjectNotFound(err error) bool {
+	return strings.Contains(err.Error(), "NotFound") || strings.Contains(err.Error(), "NoSuchKey")
+}
+
+// PrefixExists reports whether any object is stored under prefix.
+func PrefixExists(c context.Context, api S3BucketAPI, bucket string, prefix string) (bool, error) {
+	out, err := ListItems(c, api, &s3.ListObjectsV2Input{
+		Bucket:  aws.String(bucket),
+		Prefix:  aws.String(prefix),
+		MaxKeys: 1,
+	})
+	if err != nil {
+		return false, err
+	}
+
+	return len(out.Contents) > 0, nil
+}
+
+// ListDeploymentPrefixes returns the sorted prefixes of all deployments
+// stored in the bucket.
+func ListDeploymentPrefixes(c context.Context, api S3BucketAPI, bucket string) ([]string, error) {
+	paginator := s3.NewListObjectsV2Paginator(api, &s3.ListObjectsV2Input{
+		Bucket:    aws.String(bucket),
+		Prefix:    aws.String(DeploymentsPrefix),
+		Delimiter: aws.String("/"),
+	})
+
+	prefixes := []string{}
+
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(c)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, p := range page.CommonPrefixes {
+			prefixes = append(prefixes, aws.ToString(p.Prefix))
+		}
+	}
+
+	sort.Strings(prefixes)
+
+	return prefixes, nil
+}
+
+// ExpiredPrefixes returns the prefixes that are neither among the retain
+// newest nor released. Waypoint deployment IDs are ULIDs, so sorting the
+// prefixes orders them by the time the deployments were created.
+func ExpiredPrefixes(prefixes []string, retain int, released ...string) []string {
+	sorted := append([]string{}, prefixes...)
+	sort.Strings(sorted)
+
+	keep := len(sorted) - retain
+	if keep < 0 {
+		keep = 0
+	}
+
+	expired := []string{}
+
+	for _, p := range sorted[:keep] {
+		if !contains(released, p) {
+			expired = append(expired, p)
+		}
+	}
+
+	return expired
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,78 @@
+package platform
+
+import (
+	"context"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+)
+
+// HashMetadataKey is the user metadata key holding the hex MD5 of the
+// local file an object was uploaded from. The ETag alone is not enough
+// to compare content once objects are multipart uploaded or transformed.
+const HashMetadataKey = "pilot-md5"
+
+// RemoteObject describes an object already stored in the bucket.
+type RemoteObject struct {
+	Key  string
+	Size int64
+	ETag string
+}
+
+func HeadItem(c context.Context, api S3BucketAPI, input *s3.HeadObjectInput) (*s3.HeadObjectOutput, error) {
+	return api.HeadObject(c, input)
+}
+
+// ListRemoteObjects pages through every object in the bucket under prefix
+// and indexes them by key.
+func ListRemoteObjects(c context.Context, api S3BucketAPI, bucket string, prefix string) (map[string]RemoteObject, error) {
+	input := &s3.ListObjectsV2Input{
+		Bucket: aws.String(bucket),
+	}
+
+	if prefix != "" {
+		input.Prefix = aws.String(prefix)
+	}
+
+	objects := map[string]RemoteObject{}
+	paginator := s3.NewListObjectsV2Paginator(api, input)
+
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(c)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, item := range page.Contents {
+			objects[*item.Key] = RemoteObject{
+				Key:  *item.Key,
+				Size: item.Size,
+				ETag: aws.ToString(item.ETag),
+			}
+		}
+	}
+
+	return objects, nil
+}
+
+// objectUnchanged reports whether obj already holds a body with the given
+// size and hex MD5 as stored, i.e. compressed for compressed uploads. The
+// ETag of a plain single part upload is the MD5 of its body, anything else
+// falls back to comparing sum, the MD5 of the local file, with the hash
+// stored in the object metadata.
+func objectUnchanged(c context.Context, api S3BucketAPI, bucket string, obj RemoteObject, size int64, stored string, sum string) (bool, error) {
+	if obj.Size == size && strings.Trim(obj.ETag, `"`) == stored {
+		return true, nil
+	}
+
+	head, err := HeadItem(c, api, &s3.HeadObjectInput{
+		Bucket: aws.String(bucket),
+		Key:    aws.String(obj.Key),
+	})
+	if err != nil {
+		return false, err
+	}
+
+	return head.Metadata[HashMetadataKey] == sum, nil
+}
@@ -0,0 +1,299 @@
+package platform
+
+import (
+	"bytes"
+	"context"
+	"crypto/md5"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+)
+
+// DefaultConcurrency is the number of parallel uploads used when
+// none is configured.
+const DefaultConcurrency = 10
+
+// number of leading bytes used to sniff the MIME type of a file
+const sniffLen = 3072
+
+// LocalFile is a file in the build directory together with the
+// object key it is uploaded to.
+type LocalFile struct {
+	Path string
+	Key  string
+}
+
+// UploadError records a single file that failed to upload.
+type UploadError struct {
+	Key string
+	Err error
+}
+
+func (e *UploadError) Error() string {
+	return fmt.Sprintf("%v: %v", e.Key, e.Err)
+}
+
+func (e *UploadError) Unwrap() error {
+	return e.Err
+}
+
+// UploadErrors aggregates the per-file failures of a PutObjects call.
+type UploadErrors []*UploadError
+
+func (e UploadErrors) Error() string {
+	msgs := make([]string, len(e))
+	for i, err := range e {
+		msgs[i] = err.Error()
+	}
+
+	return fmt.Sprintf("%d file(s) failed to upload: %v", len(e), strings.Join(msgs, "; "))
+}
+
+// UploadOptions controls how PutObjects uploads files.
+type UploadOptions struct {
+	// Number of files uploaded in parallel, DefaultConcurrency if unset
+	Concurrency int
+	// Called after every file with the number of files processed so far.
+	// Calls are never made concurrently.
+	Progress func(done, total int)
+	// Prepended to the key of every file, e.g. the deployment's prefix.
+	// Result keys do not include it.
+	Prefix string
+	// Objects already in the bucket. When set, files whose content matches
+	// the existing object are skipped instead of uploaded again.
+	Existing map[string]RemoteObject
+	// Cache-Control rules and fallback profile, see CacheControlFor
+	CacheRules   []*CacheRule
+	CacheProfile string
+	// Content-Encoding used for text-like files, none if empty
+	Compression string
+	// Use .gz/.br siblings from the build instead of compressing
+	Precompressed bool
+	// Normalized MIME type overrides by extension, see DetectMimeType
+	ContentTypes map[string]string
+	// Size in bytes from which files are sent as multipart uploads,
+	// DefaultMultipartThreshold if unset, at most MaxMultipartThreshold
+	MultipartThreshold int64
+}
+
+// UploadResult summarises a PutObjects call. Keys are sorted.
+type UploadResult struct {
+	Uploaded []string
+	Skipped  []string
+	Failed   UploadErrors
+}
+
+// ListFiles recursively collects every file in buildDir. Object keys are
+// the slash separated paths relative to buildDir.
+func ListFiles(buildDir string) ([]LocalFile, error) {
+	files := []LocalFile{}
+
+	err := filepath.WalkDir(buildDir, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+
+		if d.IsDir() {
+			return nil
+		}
+
+		rel, err := filepath.Rel(buildDir, p)
+		if err != nil {
+			return err
+		}
+
+		files = append(files, LocalFile{
+			Path: p,
+			Key:  filepath.ToSlash(rel),
+		})
+
+		return nil
+	})
+
+	return files, err
+}
+
+// PutObjects uploads files to the bucket using a bounded pool of workers.
+// A failing file does not stop the others, failures are collected in
+// the returned result instead.
+func PutObjects(c context.Context, api S3BucketAPI, bucket string, files []LocalFile, opts UploadOptions) *UploadResult {
+	workers := opts.Concurrency
+	if workers < 1 {
+		workers = DefaultConcurrency
+	}
+
+	if workers > len(files) {
+		workers = len(files)
+	}
+
+	type outcome struct {
+		key     string
+		skipped bool
+		err     error
+	}
+
+	jobs := make(chan LocalFile)
+	outcomes := make(chan outcome)
+
+	var wg sync.WaitGroup
+	wg.Add(workers)
+
+	for i := 0; i < workers; i++ {
+		go func() {
+			defer wg.Done()
+
+			for f := range jobs {
+				skipped, err := putFile(c, api, bucket, f, opts)
+				outcomes <- outcome{key: f.Key, skipped: skipped, err: err}
+			}
+		}()
+	}
+
+	go func() {
+		for _, f := range files {
+			jobs <- f
+		}
+		close(jobs)
+		wg.Wait()
+		close(outcomes)
+	}()
+
+	result := &UploadResult{
+		Uploaded: []string{},
+		Skipped:  []string{},
+		Failed:   UploadErrors{},
+	}
+
+	done := 0
+	for o := range outcomes {
+		if o.err != nil {
+			result.Failed = append(result.Failed, &UploadError{Key: o.key, Err: o.err})
+		} else if o.skipped {
+			result.Skipped = append(result.Skipped, o.key)
+		} else {
+			result.Uploaded = append(result.Uploaded, o.key)
+		}
+
+		done++
+		if opts.Progress != nil {
+			opts.Progress(done, len(files))
+		}
+	}
+
+	sort.Strings(result.Uploaded)
+	sort.Strings(result.Skipped)
+	sort.Slice(result.Failed, func(i, j int) bool {
+		return result.Failed[i].Key < result.Failed[j].Key
+	})
+
+	return result
+}
+
+// putFile uploads a single file and reports whether it was skipped
+// because an identical object already exists. The file is streamed from
+// disk, only text-like files that get compressed are held in memory.
+func putFile(c context.Context, api S3BucketAPI, bucket string, f LocalFile, opts UploadOptions) (bool, error) {
+	file, err := os.Open(f.Path)
+	if err != nil {
+		return false, err
+	}
+	defer file.Close()
+
+	info, err := file.Stat()
+	if err != nil {
+		return false, err
+	}
+	size := info.Size()
+
+	hash := md5.New()
+	if _, err := io.Copy(hash, io.NewSectionReader(file, 0, size)); err != nil {
+		return false, err
+	}
+	sum := hex.EncodeToString(hash.Sum(nil))
+
+	key := opts.Prefix + f.Key
+
+	// the first bytes are enough to sniff the MIME type
+	head := make([]byte, sniffLen)
+	n, err := file.ReadAt(head, 0)
+	if err != nil && err != io.EOF {
+		return false, err
+	}
+	contentType := DetectMimeType(f.Path, head[:n], opts.ContentTypes)
+
+	threshold := opts.MultipartThreshold
+	if threshold < 1 {
+		threshold = DefaultMultipartThreshold
+	}
+	if threshold > MaxMultipartThreshold {
+		threshold = MaxMultipartThreshold
+	}
+
+	var body io.ReaderAt = file
+	encoding := ""
+	// MD5 of the body as stored, the ETag of a single part upload
+	stored := sum
+
+	if opts.Compression != "" && IsCompressible(contentType) && size < threshold {
+		data, err := io.ReadAll(io.NewSectionReader(file, 0, size))
+		if err != nil {
+			return false, err
+		}
+
+		compressed, enc, err := compressBody(f.Path, data, opts.Compression, opts.Precompressed)
+		if err != nil {
+			return false, err
+		}
+
+		body, size, encoding = bytes.NewReader(compressed), int64(len(compressed)), enc
+
+		if encoding != "" {
+			compressedSum := md5.Sum(compressed)
+			stored = hex.EncodeToString(compressedSum[:])
+		}
+	}
+
+	if obj, ok := opts.Existing[key]; ok {
+		unchanged, err := objectUnchanged(c, api, bucket, obj, size, stored, sum)
+		if err != nil {
+			return false, err
+		}
+
+		if unchanged {
+			return true, nil
+		}
+	}
+
+	input := &s3.PutObjectInput{
+		Bucket:      &bucket,
+		Key:         aws.String(key),
+		ContentType: aws.String(contentType),
+		Metadata:    map[string]string{HashMetadataKey: sum},
+	}
+
+	if encoding != "" {
+		input.ContentEncoding = aws.String(encoding)
+	}
+
+	if cacheControl := CacheControlFor(f.Key, opts.CacheRules, opts.CacheProfile); cacheControl != "" {
+		input.CacheControl = aws.String(cacheControl)
+	}
+
+	if size >= threshold {
+		return false, putMultipart(c, api, input, body, size)
+	}
+
+	input.Body = io.NewSectionReader(body, 0, size)
+
+	_, err = AddFile(c, api, input)
+	return false, err
+}
@@ -0,0 +1,311 @@
+package platform_test
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/pilot-framework/aws-cloudfront-waypoint-plugin/fakes"
+	"github.com/pilot-framework/aws-cloudfront-waypoint-plugin/platform"
+)
+
+// writeFiles creates the files in a new temporary directory, keyed by slash
+// separated relative path.
+func writeFiles(t *testing.T, files map[string]string) string {
+	t.Helper()
+
+	dir := t.TempDir()
+	for name, content := range files {
+		p := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(p), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(p, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	return dir
+}
+
+func newBucket(t *testing.T, client *fakes.S3, bucket string) {
+	t.Helper()
+
+	_, err := client.CreateBucket(context.Background(), &s3.CreateBucketInput{Bucket: aws.String(bucket)})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+// failingS3 fails every request for the key fail
+type failingS3 struct {
+	*fakes.S3
+	fail    string
+	aborted []string
+}
+
+func (f *failingS3) PutObject(ctx context.Context, input *s3.PutObjectInput, optFns ...func(*s3.Options)) (*s3.PutObjectOutput, error) {
+	if aws.ToString(input.Key) == f.fail {
+		return nil, errors.New("injected failure")
+	}
+
+	return f.S3.PutObject(ctx, input, optFns...)
+}
+
+func (f *failingS3) CompleteMultipartUpload(ctx context.Context, input *s3.CompleteMultipartUploadInput, optFns ...func(*s3.Options)) (*s3.CompleteMultipartUploadOutput, error) {
+	if aws.ToString(input.Key) == f.fail {
+		return nil, errors.New("injected failure")
+	}
+
+	return f.S3.CompleteMultipartUpload(ctx, input, optFns...)
+}
+
+func (f *failingS3) AbortMultipartUpload(ctx context.Context, input *s3.AbortMultipartUploadInput, optFns ...func(*s3.Options)) (*s3.AbortMultipartUploadOutput, error) {
+	f.aborted = append(f.aborted, aws.ToString(input.Key))
+
+	return f.S3.AbortMultipartUpload(ctx, input, optFns...)
+}
+
+func TestPutObjects(t *testing.T) {
+	ctx := context.Background()
+	dir := writeFiles(t, map[string]string{
+		"index.html":                 "<html></html>",
+		"static/js/main.3f2a1b9c.js": "console.log(1)",
+		"img/logo.svg":               "<svg></svg>",
+	})
+
+	files, err := platform.ListFiles(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	client := fakes.NewS3()
+	newBucket(t, client, "site")
+
+	progress := 0
+	result := platform.PutObjects(ctx, client, "site", files, platform.UploadOptions{
+		Concurrency: 2,
+		Prefix:      "deployments/01A/",
+		Progress: func(done, total int) {
+			progress = done
+		},
+	})
+
+	if len(result.Failed) > 0 {
+		t.Fatal(result.Failed)
+	}
+
+	want := []string{"img/logo.svg", "index.html", "static/js/main.3f2a1b9c.js"}
+	if !reflect.DeepEqual(result.Uploaded, want) {
+		t.Errorf("Uploaded = %v, want %v", result.Uploaded, want)
+	}
+	if progress != len(files) {
+		t.Errorf("progress reported %d of %d files", progress, len(files))
+	}
+
+	wantKeys := []string{"deployments/01A/img/logo.svg", "deployments/01A/index.html", "deployments/01A/static/js/main.3f2a1b9c.js"}
+	if keys := client.Keys("site"); !reflect.DeepEqual(keys, wantKeys) {
+		t.Errorf("bucket keys = %v, want %v", keys, wantKeys)
+	}
+
+	objects := client.Bucket("site").Objects
+	if got := objects["deployments/01A/img/logo.svg"].ContentType; got != "image/svg+xml" {
+		t.Errorf("svg content type = %q", got)
+	}
+	if got := objects["deployments/01A/static/js/main.3f2a1b9c.js"].CacheControl; got != platform.ImmutableCacheControl {
+		t.Errorf("hashed asset cache control = %q", got)
+	}
+	if got := objects["deployments/01A/index.html"].CacheControl; got != "" {
+		t.Errorf("index.html cache control = %q", got)
+	}
+}
+
+func TestPutObjectsSkipsUnchanged(t *testing.T) {
+	ctx := context.Background()
+	dir := writeFiles(t, map[string]string{
+		"index.html": "<html>v1</html>",
+		"app.js":     "v1",
+	})
+
+	files, err := platform.ListFiles(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	client := fakes.NewS3()
+	newBucket(t, client, "site")
+
+	platform.PutObjects(ctx, client, "site", files, platform.UploadOptions{})
+
+	if err := os.WriteFile(filepath.Join(dir, "app.js"), []byte("v2"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	existing, err := platform.ListRemoteObjects(ctx, client, "site", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	result := platform.PutObjects(ctx, client, "site", files, platform.UploadOptions{Existing: existing})
+
+	if !reflect.DeepEqual(result.Uploaded, []string{"app.js"}) || !reflect.DeepEqual(result.Skipped, []string{"index.html"}) {
+		t.Errorf("Uploaded = %v, Skipped = %v", result.Uploaded, result.Skipped)
+	}
+	if got := string(client.Bucket("site").Objects["app.js"].Body); got != "v2" {
+		t.Errorf("app.js = %q, want v2", got)
+	}
+}
+
+// headCounter counts the HeadObject calls
+type headCounter struct {
+	*fakes.S3
+	heads int
+}
+
+func (h *headCounter) HeadObject(ctx context.Context, input *s3.HeadObjectInput, optFns ...func(*s3.Options)) (*s3.HeadObjectOutput, error) {
+	h.heads++
+
+	return h.S3.HeadObject(ctx, input, optFns...)
+}
+
+func TestPutObjectsSkipsUnchangedCompressed(t *testing.T) {
+	ctx := context.Background()
+	dir := writeFiles(t, map[string]string{
+		"index.html": strings.Repeat("<p>hello</p>", 100),
+		"app.js":     strings.Repeat("console.log(1)\n", 100),
+		"logo.png":   "\x89PNG\r\n\x1a\n",
+	})
+
+	files, err := platform.ListFiles(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	client := &headCounter{S3: fakes.NewS3()}
+	newBucket(t, client.S3, "site")
+
+	opts := platform.UploadOptions{Compression: platform.EncodingGzip}
+	platform.PutObjects(ctx, client, "site", files, opts)
+
+	objects := client.Bucket("site").Objects
+	if got := objects["app.js"].ContentEncoding; got != platform.EncodingGzip {
+		t.Errorf("app.js content encoding = %q", got)
+	}
+	if got := objects["logo.png"].ContentEncoding; got != "" {
+		t.Errorf("logo.png content encoding = %q", got)
+	}
+
+	if err := os.WriteFile(filepath.Join(dir, "app.js"), []byte(strings.Repeat("console.log(2)\n", 100)), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	opts.Existing, err = platform.ListRemoteObjects(ctx, client, "site", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	result := platform.PutObjects(ctx, client, "site", files, opts)
+
+	if !reflect.DeepEqual(result.Uploaded, []string{"app.js"}) || !reflect.DeepEqual(result.Skipped, []string{"index.html", "logo.png"}) {
+		t.Errorf("Uploaded = %v, Skipped = %v", result.Uploaded, result.Skipped)
+	}
+	// the listing is enough to tell the compressed objects are unchanged,
+	// only the changed one is checked against its metadata
+	if client.heads != 1 {
+		t.Errorf("%d HeadObject calls, want 1", client.heads)
+	}
+}
+
+func TestPutObjectsCollectsFailures(t *testing.T) {
+	ctx := context.Background()
+	dir := writeFiles(t, map[string]string{
+		"a.txt": "a",
+		"b.txt": "b",
+		"c.txt": "c",
+	})
+
+	files, err := platform.ListFiles(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	client := &failingS3{S3: fakes.NewS3(), fail: "b.txt"}
+	newBucket(t, client.S3, "site")
+
+	result := platform.PutObjects(ctx, client, "site", files, platform.UploadOptions{Concurrency: 3})
+
+	if len(result.Failed) != 1 || result.Failed[0].Key != "b.txt" {
+		t.Fatalf("Failed = %v, want b.txt", result.Failed)
+	}
+	if !strings.Contains(result.Failed.Error(), "injected failure") {
+		t.Errorf("error %q does not name the cause", result.Failed.Error())
+	}
+	if !reflect.DeepEqual(result.Uploaded, []string{"a.txt", "c.txt"}) {
+		t.Errorf("Uploaded = %v", result.Uploaded)
+	}
+}
+
+func TestPutObjectsMultipart(t *testing.T) {
+	ctx := context.Background()
+	dir := writeFiles(t, map[string]string{
+		"video.mp4": strings.Repeat("x", 1024),
+		"small.txt": "small",
+	})
+
+	files, err := platform.ListFiles(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	client := fakes.NewS3()
+	newBucket(t, client, "site")
+
+	result := platform.PutObjects(ctx, client, "site", files, platform.UploadOptions{MultipartThreshold: 512})
+	if len(result.Failed) > 0 {
+		t.Fatal(result.Failed)
+	}
+
+	video := client.Bucket("site").Objects["video.mp4"]
+	if len(video.Body) != 1024 || video.ContentType != "video/mp4" {
+		t.Errorf("video.mp4 has %d bytes of %q", len(video.Body), video.ContentType)
+	}
+	if !strings.HasSuffix(video.ETag, `-1"`) {
+		t.Errorf("video.mp4 was not uploaded in parts, ETag %v", video.ETag)
+	}
+	if strings.Contains(client.Bucket("site").Objects["small.txt"].ETag, "-") {
+		t.Error("small.txt was uploaded in parts")
+	}
+}
+
+func TestPutObjectsAbortsFailedMultipart(t *testing.T) {
+	ctx := context.Background()
+	dir := writeFiles(t, map[string]string{
+		"video.mp4": strings.Repeat("x", 1024),
+	})
+
+	files, err := platform.ListFiles(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	client := &failingS3{S3: fakes.NewS3(), fail: "video.mp4"}
+	newBucket(t, client.S3, "site")
+
+	result := platform.PutObjects(ctx, client, "site", files, platform.UploadOptions{MultipartThreshold: 512})
+
+	if len(result.Failed) != 1 {
+		t.Fatalf("Failed = %v, want video.mp4", result.Failed)
+	}
+	if !reflect.DeepEqual(client.aborted, []string{"video.mp4"}) {
+		t.Errorf("aborted uploads = %v", client.aborted)
+	}
+	if keys := client.Keys("site"); len(keys) != 0 {
+		t.Errorf("bucket keys = %v, want none", keys)
+	}
+}
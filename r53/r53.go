@@ -0,0 +1,103 @@
+package r53
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/route53"
+	"github.com/aws/aws-sdk-go-v2/service/route53/types"
+)
+
+// CloudFrontHostedZoneId is the hosted zone every CloudFront distribution
+// domain belongs to, used as the target zone of alias records
+const CloudFrontHostedZoneId = "Z2FDTNDATAQYW2"
+
+// Defines interface for needed Route 53 functions
+type Route53API interface {
+	ChangeResourceRecordSets(
+		ctx context.Context,
+		input *route53.ChangeResourceRecordSetsInput,
+		optFns ...func(*route53.Options),
+	) (*route53.ChangeResourceRecordSetsOutput, error)
+}
+
+func ChangeRecords(
+	c context.Context,
+	api Route53API,
+	input *route53.ChangeResourceRecordSetsInput,
+) (*route53.ChangeResourceRecordSetsOutput, error) {
+	return api.ChangeResourceRecordSets(c, input)
+}
+
+// FormatAliasChanges creates an A and an AAAA alias record change for
+// every domain, pointing at the distribution's domain name
+func FormatAliasChanges(action types.ChangeAction, domains []string, distributionDomain string) []types.Change {
+	changes := []types.Change{}
+
+	for _, domain := range domains {
+		for _, recordType := range []types.RRType{types.RRTypeA, types.RRTypeAaaa} {
+			changes = append(changes, types.Change{
+				Action: action,
+				ResourceRecordSet: &types.ResourceRecordSet{
+					Name: aws.String(domain),
+					Type: recordType,
+					AliasTarget: &types.AliasTarget{
+						DNSName:              aws.String(distributionDomain),
+						HostedZoneId:         aws.String(CloudFrontHostedZoneId),
+						EvaluateTargetHealth: false,
+					},
+				},
+			})
+		}
+	}
+
+	return changes
+}
+
+// UpsertAliases creates or updates the alias records of domains in the hosted zone
+func UpsertAliases(c context.Context, api Route53API, zoneId string, domains []string, distributionDomain string) error {
+	_, err := ChangeRecords(c, api, &route53.ChangeResourceRecordSetsInput{
+		HostedZoneId: aws.String(zoneId),
+		ChangeBatch: &types.ChangeBatch{
+			Comment: aws.String("Managed via Pilot"),
+			Changes: FormatAliasChanges(types.ChangeActionUpsert, domains, distributionDomain),
+		},
+	})
+
+	return err
+}
+
+// DeleteAliases removes the alias records of domains from the hosted zone.
+// Records that no longer exist or point somewhere else than
+// distributionDomain by now are left alone.
+func DeleteAliases(c context.Context, api Route53API, zoneId string, domains []string, distributionDomain string) error {
+	// a batch is rejected as a whole if one record is missing, so delete one by one
+	for _, change := range FormatAliasChanges(types.ChangeActionDelete, domains, distributionDomain) {
+		_, err := ChangeRecords(c, api, &route53.ChangeResourceRecordSetsInput{
+			HostedZoneId: aws.String(zoneId),
+			ChangeBatch: &types.ChangeBatch{
+				Changes: []types.Change{change},
+			},
+		})
+
+		if err != nil && !RecordNotFound(err) && !RecordMismatch(err) {
+			return fmt.Errorf("deleting %v record for %v: %w", change.ResourceRecordSet.Type, *change.ResourceRecordSet.Name, err)
+		}
+	}
+
+	return nil
+}
+
+// RecordNotFound reports whether a change failed because the record it
+// deletes does not exist
+func RecordNotFound(err error) bool {
+	return strings.Contains(err.Error(), "InvalidChangeBatch") && strings.Contains(err.Error(), "not found")
+}
+
+// RecordMismatch reports whether a change failed because the record it
+// deletes exists with other values, e.g. an alias to another target
+func RecordMismatch(err error) bool {
+	return strings.Contains(err.Error(), "InvalidChangeBatch") && strings.Contains(err.Error(), "do not match")
+}
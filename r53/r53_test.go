@@ -0,0 +1,45 @@
+package r53_test
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/pilot-framework/aws-cloudfront-waypoint-plugin/fakes"
+	"github.com/pilot-framework/aws-cloudfront-waypoint-plugin/r53"
+)
+
+func TestDeleteAliases(t *testing.T) {
+	ctx := context.Background()
+	client := fakes.NewRoute53()
+	client.AddHostedZone("Z1")
+
+	if err := r53.UpsertAliases(ctx, client, "Z1", []string{"www.example.com"}, "d1.cloudfront.net"); err != nil {
+		t.Fatal(err)
+	}
+	// pointed at another distribution since
+	if err := r53.UpsertAliases(ctx, client, "Z1", []string{"example.com"}, "d2.cloudfront.net"); err != nil {
+		t.Fatal(err)
+	}
+
+	// missing records and records of other targets are left alone
+	err := r53.DeleteAliases(ctx, client, "Z1", []string{"www.example.com", "example.com", "old.example.com"}, "d1.cloudfront.net")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	left := []string{}
+	for _, record := range client.Records("Z1") {
+		left = append(left, aws.ToString(record.Name)+" "+string(record.Type)+" "+aws.ToString(record.AliasTarget.DNSName))
+	}
+
+	want := []string{"example.com A d2.cloudfront.net", "example.com AAAA d2.cloudfront.net"}
+	if !reflect.DeepEqual(left, want) {
+		t.Errorf("records = %v, want %v", left, want)
+	}
+
+	if err := r53.DeleteAliases(ctx, client, "Z2", []string{"example.com"}, "d2.cloudfront.net"); err == nil {
+		t.Error("deleted from a missing hosted zone")
+	}
+}
@@ -0,0 +1,100 @@
+package release
+
+import (
+	"context"
+
+	"github.com/hashicorp/waypoint-plugin-sdk/component"
+	"github.com/hashicorp/waypoint-plugin-sdk/terminal"
+	"github.com/pilot-framework/aws-cloudfront-waypoint-plugin/awsclient"
+	"github.com/pilot-framework/aws-cloudfront-waypoint-plugin/creds"
+	"github.com/pilot-framework/aws-cloudfront-waypoint-plugin/platform"
+)
+
+func (rm *ReleaseManager) ValidateAuthFunc() interface{} {
+	return rm.validateAuth
+}
+
+// AuthFunc satisfies the Authenticator interface
+func (rm *ReleaseManager) AuthFunc() interface{} {
+	return rm.authenticate
+}
+
+// RequiredActions returns the IAM actions releasing and destroying the
+// deployment need. Releasing an oac deployment sets the bucket policy, an
+// immutable one records the released prefix in the bucket. Without a
+// deployment, e.g. when validating the credentials up front, the actions
+// for any deployment are returned. The continuous deployment actions are
+// only needed for canary releases.
+func (rm *ReleaseManager) RequiredActions(deployment *platform.Deployment) []string {
+	actions := []string{
+		"cloudfront:CreateDistribution",
+		"cloudfront:CreateInvalidation",
+		"cloudfront:CreateOriginAccessControl",
+		"cloudfront:DeleteDistribution",
+		"cloudfront:DeleteOriginAccessControl",
+		"cloudfront:GetDistribution",
+		"cloudfront:GetDistributionConfig",
+		"cloudfront:GetInvalidation",
+		"cloudfront:GetOriginAccessControl",
+		"cloudfront:ListDistributions",
+		"cloudfront:ListOriginAccessControls",
+		"cloudfront:ListTagsForResource",
+		"cloudfront:TagResource",
+		"cloudfront:UpdateDistribution",
+		// destroy reads the released prefix of the bucket
+		"s3:GetObject",
+	}
+
+	if deployment == nil || deployment.Access == platform.AccessOAC {
+		actions = append(actions, "s3:PutBucketPolicy")
+	}
+
+	if deployment == nil || deployment.Prefix != "" {
+		actions = append(actions, "s3:DeleteObject", "s3:ListBucket", "s3:PutObject")
+	}
+
+	if len(rm.config.Aliases) > 0 && rm.config.CertificateArn == "" {
+		actions = append(actions, "acm:ListCertificates")
+	}
+
+	if rm.config.HostedZoneId != "" {
+		actions = append(actions, "route53:ChangeResourceRecordSets")
+	}
+
+	if rm.config.Canary != nil {
+		actions = append(actions,
+			"cloudfront:CopyDistribution",
+			"cloudfront:CreateContinuousDeploymentPolicy",
+			"cloudfront:DeleteContinuousDeploymentPolicy",
+			"cloudfront:GetContinuousDeploymentPolicy",
+			"cloudfront:UpdateContinuousDeploymentPolicy",
+			"cloudfront:UpdateDistributionWithStagingConfig",
+		)
+	}
+
+	return actions
+}
+
+// If an error is returned, Waypoint will attempt to call
+// AuthFunc
+func (rm *ReleaseManager) validateAuth(ctx context.Context, ui terminal.UI) error {
+	// CloudFront stand-ins accept any credentials
+	if rm.config.CloudfrontEndpoint != "" {
+		return nil
+	}
+
+	f, err := awsclient.New(ctx, clientSettings(&rm.config, ""))
+	if err != nil {
+		ui.Output("AWS configuration error, "+err.Error(), terminal.WithErrorStyle())
+		return err
+	}
+
+	return creds.Validate(ctx, ui, f.STS(), f.IAM(), rm.RequiredActions(nil))
+}
+
+// Output parameters must be *component.AuthResult, error
+func (rm *ReleaseManager) authenticate(ctx context.Context, ui terminal.UI) (*component.AuthResult, error) {
+	ui.Output(creds.Guidance(rm.RequiredActions(nil)))
+
+	return &component.AuthResult{Authenticated: false}, nil
+}
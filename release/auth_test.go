@@ -0,0 +1,93 @@
+package release_test
+
+import (
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/pilot-framework/aws-cloudfront-waypoint-plugin/platform"
+	"github.com/pilot-framework/aws-cloudfront-waypoint-plugin/release"
+)
+
+func TestRequiredActions(t *testing.T) {
+	s3Actions := func(actions []string) []string {
+		found := []string{}
+		for _, action := range actions {
+			if strings.HasPrefix(action, "s3:") {
+				found = append(found, action)
+			}
+		}
+
+		sort.Strings(found)
+
+		return found
+	}
+
+	tests := []struct {
+		name       string
+		deployment *platform.Deployment
+		want       []string
+	}{
+		{
+			name:       "public",
+			deployment: &platform.Deployment{Bucket: "site", Access: platform.AccessPublic},
+			want:       []string{"s3:GetObject"},
+		},
+		{
+			name:       "oac",
+			deployment: &platform.Deployment{Bucket: "site", Access: platform.AccessOAC},
+			want:       []string{"s3:GetObject", "s3:PutBucketPolicy"},
+		},
+		{
+			name:       "immutable",
+			deployment: &platform.Deployment{Bucket: "site", Access: platform.AccessPublic, Prefix: "deployments/01A/"},
+			want:       []string{"s3:DeleteObject", "s3:GetObject", "s3:ListBucket", "s3:PutObject"},
+		},
+		{
+			name: "any deployment",
+			want: []string{"s3:DeleteObject", "s3:GetObject", "s3:ListBucket", "s3:PutBucketPolicy", "s3:PutObject"},
+		},
+	}
+
+	rm := newReleaseManager(t, release.ReleaseConfig{})
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := s3Actions(rm.RequiredActions(tt.deployment)); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("S3 actions = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRequiredActionsFromConfig(t *testing.T) {
+	tests := []struct {
+		name    string
+		config  release.ReleaseConfig
+		action  string
+		allowed bool
+	}{
+		{"certificate lookup", release.ReleaseConfig{Aliases: []string{"example.com"}}, "acm:ListCertificates", true},
+		{"certificate given", release.ReleaseConfig{Aliases: []string{"example.com"}, CertificateArn: "arn:aws:acm:us-east-1:123456789012:certificate/1"}, "acm:ListCertificates", false},
+		{"hosted zone", release.ReleaseConfig{Aliases: []string{"example.com"}, HostedZoneId: "Z1"}, "route53:ChangeResourceRecordSets", true},
+		{"without hosted zone", release.ReleaseConfig{}, "route53:ChangeResourceRecordSets", false},
+		{"canary", release.ReleaseConfig{Canary: &release.CanaryConfig{Weight: 0.1}}, "cloudfront:CopyDistribution", true},
+		{"without canary", release.ReleaseConfig{}, "cloudfront:CopyDistribution", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			actions := newReleaseManager(t, tt.config).RequiredActions(nil)
+
+			found := false
+			for _, action := range actions {
+				found = found || action == tt.action
+			}
+
+			if found != tt.allowed {
+				t.Errorf("%v required = %v, want %v", tt.action, found, tt.allowed)
+			}
+		})
+	}
+}
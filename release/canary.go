@@ -0,0 +1,260 @@
+package release
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/hashicorp/waypoint-plugin-sdk/terminal"
+	"github.com/pilot-framework/aws-cloudfront-waypoint-plugin/cfront"
+	"github.com/pilot-framework/aws-cloudfront-waypoint-plugin/platform"
+)
+
+// What a release with a canary block does
+const (
+	// CanaryStart serves the deployment from a staging distribution
+	// receiving part of the traffic
+	CanaryStart = "start"
+	// CanaryPromote serves the deployment of the canary from the distribution
+	CanaryPromote = "promote"
+	// CanaryAbort removes the canary, the distribution keeps serving what it did
+	CanaryAbort = "abort"
+)
+
+// CanaryConfig releases through a CloudFront staging distribution that
+// receives part of the traffic until it is promoted or aborted
+type CanaryConfig struct {
+	// "start" (default), "promote" or "abort"
+	Action string `hcl:"action,optional"`
+	// Share of requests routed to the canary, at most 0.15
+	Weight float64 `hcl:"weight,optional"`
+	// Route requests with this header to the canary instead of a share of
+	// all requests, it has to start with aws-cf-cd-
+	Header      string `hcl:"header,optional"`
+	HeaderValue string `hcl:"header_value,optional"`
+}
+
+func (c *CanaryConfig) action() string {
+	if c.Action == "" {
+		return CanaryStart
+	}
+
+	return c.Action
+}
+
+func (c *CanaryConfig) options() cfront.CanaryOptions {
+	return cfront.CanaryOptions{
+		Weight:      float32(c.Weight),
+		Header:      c.Header,
+		HeaderValue: c.HeaderValue,
+	}
+}
+
+func validateCanary(c *CanaryConfig) error {
+	// promote and abort keep the routing of the running canary
+	switch c.action() {
+	case CanaryPromote, CanaryAbort:
+		return nil
+	case CanaryStart:
+	default:
+		return fmt.Errorf("canary action must be %q, %q or %q, got: %v", CanaryStart, CanaryPromote, CanaryAbort, c.Action)
+	}
+
+	if (c.Weight > 0) == (c.Header != "") {
+		return fmt.Errorf("canary needs either weight or header")
+	}
+
+	if c.Weight < 0 || c.Weight > cfront.MaxCanaryWeight {
+		return fmt.Errorf("canary weight must be between 0 and %v, got: %v", cfront.MaxCanaryWeight, c.Weight)
+	}
+
+	if c.Header != "" {
+		if !strings.HasPrefix(strings.ToLower(c.Header), cfront.CanaryHeaderPrefix) {
+			return fmt.Errorf("canary header must start with %v, got: %v", cfront.CanaryHeaderPrefix, c.Header)
+		}
+
+		if c.HeaderValue == "" {
+			return fmt.Errorf("canary header_value must be specified with header")
+		}
+	}
+
+	return nil
+}
+
+// canaryPlan is what a release does with the canary of the distribution
+type canaryPlan struct {
+	// one of the canary actions, empty for a release without canary
+	Action string
+	// the canary attached to the distribution, if any
+	Active *cfront.Canary
+	// deployment prefixes the distribution and the canary serve afterwards
+	PrimaryPrefix string
+	StagedPrefix  string
+}
+
+// planCanary decides which deployments the distribution and its canary serve
+// after the release of prefix. A canary in progress has to be promoted or
+// aborted before deployments can be released without one.
+func (rm *ReleaseManager) planCanary(
+	ctx context.Context,
+	u terminal.Status,
+	clients *Clients,
+	finder *cfront.DistributionFinder,
+	target *platform.Deployment,
+	distId string,
+	prefix string,
+) (*canaryPlan, error) {
+	plan := &canaryPlan{PrimaryPrefix: prefix}
+
+	var err error
+	if distId != "" {
+		plan.Active, err = cfront.FindCanary(ctx, clients.Cloudfront, finder, distId)
+		if err != nil {
+			u.Step(terminal.StatusError, "Could not look up the canary of distribution "+distId+": "+err.Error())
+			return nil, err
+		}
+	}
+
+	if rm.config.Canary == nil {
+		if plan.Active != nil {
+			err = fmt.Errorf("a canary of distribution %v is in progress, promote or abort it first", distId)
+			u.Step(terminal.StatusError, err.Error())
+			return nil, err
+		}
+
+		return plan, nil
+	}
+
+	if prefix == "" {
+		err = fmt.Errorf("canary requires immutable deployments")
+		u.Step(terminal.StatusError, err.Error())
+		return nil, err
+	}
+
+	released, staged, err := platform.ReleasedPrefix(ctx, clients.S3, target.Bucket)
+	if err != nil {
+		u.Step(terminal.StatusError, "Could not find the released deployment in "+target.Bucket)
+		return nil, err
+	}
+
+	switch rm.config.Canary.action() {
+	case CanaryStart:
+		if distId == "" || released == "" {
+			u.Step(terminal.StatusWarn, "Nothing is released yet to compare a canary with, releasing "+prefix+" directly")
+			return plan, nil
+		}
+
+		if released == prefix {
+			u.Step(terminal.StatusWarn, prefix+" is already released, there is nothing to stage")
+			return plan, nil
+		}
+
+		plan.Action = CanaryStart
+		plan.PrimaryPrefix = released
+		plan.StagedPrefix = prefix
+	case CanaryPromote:
+		if plan.Active == nil || plan.Active.StagingId == "" {
+			err = fmt.Errorf("there is no canary to promote")
+			u.Step(terminal.StatusError, err.Error())
+			return nil, err
+		}
+
+		if staged != prefix {
+			err = fmt.Errorf("the canary serves %v, not %v", staged, prefix)
+			u.Step(terminal.StatusError, err.Error())
+			return nil, err
+		}
+
+		plan.Action = CanaryPromote
+	case CanaryAbort:
+		if plan.Active == nil {
+			u.Step(terminal.StatusWarn, "There is no canary to abort")
+		}
+
+		plan.Action = CanaryAbort
+		if released != "" {
+			plan.PrimaryPrefix = released
+		}
+	}
+
+	return plan, nil
+}
+
+// stageCanary points the canary to the staged deployment, creating the
+// staging distribution and policy if there is no canary yet.
+func (rm *ReleaseManager) stageCanary(
+	ctx context.Context,
+	u terminal.Status,
+	client cfront.CloudfrontAPI,
+	distId string,
+	origin string,
+	plan *canaryPlan,
+) (*cfront.Canary, error) {
+	originPath := cfront.OriginPath(plan.StagedPrefix, rm.config.Root)
+	opts := rm.config.Canary.options()
+
+	if plan.Active != nil && plan.Active.StagingId != "" {
+		u.Update("Updating canary of distribution " + distId + "...")
+
+		err := cfront.UpdateCanary(ctx, client, plan.Active, origin, originPath, opts)
+		if err != nil {
+			u.Step(terminal.StatusError, "Could not update canary: "+err.Error())
+			return nil, err
+		}
+
+		return plan.Active, nil
+	}
+
+	u.Update("Creating staging distribution for " + plan.StagedPrefix + "...")
+
+	canary, err := cfront.StartCanary(ctx, client, distId, origin, originPath, opts)
+	if err != nil {
+		u.Step(terminal.StatusError, "Could not start canary: "+err.Error())
+
+		// best effort, the error that matters was reported already
+		if canary != nil {
+			_ = rm.retireCanary(ctx, u, client, distId, canary, "")
+		}
+
+		return nil, err
+	}
+
+	return canary, nil
+}
+
+// retireCanary detaches the canary from the distribution and leaves the
+// staging distribution disabled, to be deleted once it deployed. oacId is
+// removed along with the staging distribution, it must be empty while the
+// distribution still uses it.
+func (rm *ReleaseManager) retireCanary(
+	ctx context.Context,
+	u terminal.Status,
+	client cfront.CloudfrontAPI,
+	distId string,
+	canary *cfront.Canary,
+	oacId string,
+) error {
+	if canary.PolicyId != "" {
+		u.Update("Removing continuous deployment policy " + canary.PolicyId + "...")
+
+		err := cfront.DetachCanary(ctx, client, distId, canary.PolicyId)
+		if err != nil {
+			u.Step(terminal.StatusError, "Could not remove continuous deployment policy "+canary.PolicyId+": "+err.Error())
+			return err
+		}
+	}
+
+	if canary.StagingId != "" {
+		u.Update("Disabling staging distribution " + canary.StagingId + "...")
+
+		err := cfront.RetireStaging(ctx, client, canary.StagingId, oacId)
+		if err != nil {
+			u.Step(terminal.StatusError, "Could not disable staging distribution "+canary.StagingId+": "+err.Error())
+			return err
+		}
+	}
+
+	u.Step(terminal.StatusOK, "Removed canary, the staging distribution is deleted once it is disabled")
+
+	return nil
+}
@@ -2,42 +2,363 @@ package release
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
 
-	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/service/cloudfront"
+	"github.com/aws/aws-sdk-go-v2/service/cloudfront/types"
 	"github.com/hashicorp/waypoint-plugin-sdk/terminal"
+	"github.com/pilot-framework/aws-cloudfront-waypoint-plugin/awsclient"
 	"github.com/pilot-framework/aws-cloudfront-waypoint-plugin/cfront"
+	"github.com/pilot-framework/aws-cloudfront-waypoint-plugin/platform"
+	"github.com/pilot-framework/aws-cloudfront-waypoint-plugin/r53"
 )
 
+// DefaultDeleteTimeout is how long destroy waits for a disabled distribution
+const DefaultDeleteTimeout = 20 * time.Minute
+
 // Implement the Destroyer interface
 func (rm *ReleaseManager) DestroyFunc() interface{} {
 	return rm.destroy
 }
 
 func (rm *ReleaseManager) destroy(ctx context.Context, ui terminal.UI, release *Release) error {
+	f, err := awsclient.New(ctx, clientSettings(&rm.config, release.Region))
+	if err != nil {
+		ui.Output("AWS configuration error, "+err.Error(), terminal.WithErrorStyle())
+		return err
+	}
+
+	return rm.Destroy(ctx, ui, release, newClients(f))
+}
+
+// Destroy removes the DNS records of the release and deletes its
+// distribution through clients. A distribution a later release serves is
+// left in place.
+func (rm *ReleaseManager) Destroy(ctx context.Context, ui terminal.UI, release *Release, clients *Clients) error {
 	u := ui.Status()
 	defer u.Close()
 	u.Step("", "\n--- Destroying AWS Cloudfront Distribution ---")
 
-	cfg, err := config.LoadDefaultConfig(context.TODO())
+	client := clients.Cloudfront
+
+	finder := cfront.NewDistributionFinder(client)
+	rm.resumeDeletions(ctx, u, client, finder, release.Bucket)
+
+	// releases of an existing distribution used to be recorded without it
+	if release.Id == "" {
+		if err := rm.removeAliases(ctx, u, clients, release, release.Aliases); err != nil {
+			return err
+		}
+
+		u.Step(terminal.StatusWarn, "The release does not record its distribution, it is left in place")
+		rm.clearReleased(ctx, u, clients.S3, release)
+		return nil
+	}
+
+	u.Update("Retrieving distribution...")
+
+	dist, err := cfront.GetDistribution(ctx, client, &cloudfront.GetDistributionInput{
+		Id: &release.Id,
+	})
 	if err != nil {
-		u.Step(terminal.StatusError, "AWS configuration error, "+err.Error())
+		if cfront.DistributionNotFound(err) {
+			if err := rm.removeAliases(ctx, u, clients, release, release.Aliases); err != nil {
+				return err
+			}
+
+			u.Step(terminal.StatusOK, "Distribution "+release.Id+" was already deleted")
+
+			// the staging distribution is deleted by the next release or destroy
+			_, err = rm.retireCanaries(ctx, u, client, release, false)
+			if err != nil {
+				return err
+			}
+
+			rm.clearReleased(ctx, u, clients.S3, release)
+			return rm.removeOriginAccessControl(ctx, u, client, release.OacId)
+		}
+
+		u.Step(terminal.StatusError, "Could not retrieve distribution: "+err.Error())
+		return err
+	}
+
+	owner, err := rm.ownsDistribution(ctx, clients, finder, release, dist)
+	if err != nil {
+		u.Step(terminal.StatusError, "Could not check which release distribution "+release.Id+" serves: "+err.Error())
+		return err
+	}
+
+	// a later release serves the distribution, its aliases and marker stay
+	if !owner {
+		err = rm.removeAliases(ctx, u, clients, release, droppedAliases(release.Aliases, dist.Distribution.DistributionConfig))
+		if err != nil {
+			return err
+		}
+
+		u.Step(terminal.StatusOK, "Distribution "+release.Id+" serves a later release, it is left in place")
+		return nil
+	}
+
+	if err := rm.removeAliases(ctx, u, clients, release, release.Aliases); err != nil {
 		return err
 	}
 
-	client := cloudfront.NewFromConfig(cfg)
+	staging, err := rm.retireCanaries(ctx, u, client, release, true)
+	if err != nil {
+		return err
+	}
 
 	u.Update("Disabling distribution...")
 
-	err = cfront.DisableDistribution(release.Id, client)
+	err = cfront.DisableDistribution(ctx, client, release.Id)
+	if err != nil {
+		u.Step(terminal.StatusError, "Could not disable distribution: "+err.Error())
+		return err
+	}
+
+	// marked before waiting, so that the deletion is resumed even if this run is interrupted
+	err = cfront.MarkPendingDeletion(ctx, client, *dist.Distribution.ARN, release.OacId)
+	if err != nil {
+		u.Step(terminal.StatusError, "Could not mark distribution for deletion: "+err.Error())
+		return err
+	}
+
+	u.Step(terminal.StatusOK, "Disabled distribution "+release.Id)
+
+	// the deployment is no longer served and may be garbage collected
+	rm.clearReleased(ctx, u, clients.S3, release)
+
+	err = cfront.PollStatus(ctx, client, release.Id, cfront.NewWaiter(rm.deleteTimeout(), func(status string) {
+		u.Update("Waiting for distribution to be disabled, status: " + status + "...")
+	}))
+	if errors.Is(err, cfront.ErrWaitTimeout) {
+		u.Step(terminal.StatusWarn, "Distribution "+release.Id+" is still being disabled, it will be deleted by the next release or destroy")
+		return nil
+	}
+	if err != nil {
+		u.Step(terminal.StatusError, "Could not wait for distribution: "+err.Error())
+		return err
+	}
+
+	u.Update("Deleting distribution...")
+
+	err = cfront.RemoveDistribution(ctx, client, release.Id)
+	if err != nil {
+		u.Step(terminal.StatusError, "Could not delete distribution: "+err.Error())
+		return err
+	}
+
+	u.Step(terminal.StatusOK, "Deleted distribution "+release.Id)
+
+	if release.OacId != "" {
+		err = cfront.WaitForDeleted(ctx, client, release.Id, cfront.NewWaiter(cfront.DeleteWaitTimeout, func(status string) {
+			u.Update("Waiting for distribution to be deleted, status: " + status + "...")
+		}))
+		if err != nil {
+			u.Step(terminal.StatusError, "Could not wait for distribution deletion: "+err.Error())
+			return err
+		}
+	}
+
+	if len(staging) > 0 {
+		for _, id := range staging {
+			err = cfront.PollStatus(ctx, client, id, cfront.NewWaiter(rm.deleteTimeout(), func(status string) {
+				u.Update("Waiting for staging distribution " + id + " to be disabled, status: " + status + "...")
+			}))
+			if err != nil && !cfront.DistributionNotFound(err) {
+				u.Step(terminal.StatusWarn, "Staging distribution "+id+" is still being disabled, it will be deleted by the next release or destroy")
+				return nil
+			}
+		}
+
+		rm.resumeDeletions(ctx, u, client, cfront.NewDistributionFinder(client), release.Bucket)
+	}
+
+	return rm.removeOriginAccessControl(ctx, u, client, release.OacId)
+}
+
+// ownsDistribution reports whether the release is the last one of the
+// distribution. Immutable releases must still be the released deployment of
+// the bucket. Releases record the generation the distribution is tagged
+// with, older ones are compared by the ETag they left it with.
+func (rm *ReleaseManager) ownsDistribution(
+	ctx context.Context,
+	clients *Clients,
+	finder *cfront.DistributionFinder,
+	release *Release,
+	dist *cloudfront.GetDistributionOutput,
+) (bool, error) {
+	if release.Prefix != "" && release.Bucket != "" {
+		prefix, _, err := platform.ReleasedPrefix(ctx, clients.S3, release.Bucket)
+		if err != nil {
+			return false, err
+		}
 
+		if prefix != release.Prefix {
+			return false, nil
+		}
+	}
+
+	if release.Generation == "" {
+		return release.Prefix != "" || release.Etag == aws.ToString(dist.ETag), nil
+	}
+
+	tags, err := finder.Tags(ctx, aws.ToString(dist.Distribution.ARN))
 	if err != nil {
+		return false, err
+	}
+
+	return tags[cfront.ReleaseTagKey] == release.Generation, nil
+}
+
+// removeAliases deletes the DNS records of the aliases if the release
+// manages them in a hosted zone.
+func (rm *ReleaseManager) removeAliases(ctx context.Context, u terminal.Status, clients *Clients, release *Release, aliases []string) error {
+	if release.HostedZoneId == "" || len(aliases) == 0 {
+		return nil
+	}
+
+	u.Update("Removing DNS records...")
+
+	err := r53.DeleteAliases(ctx, clients.Route53, release.HostedZoneId, aliases, release.DomainName)
+	if err != nil {
+		u.Step(terminal.StatusError, "Could not remove DNS records: "+err.Error())
 		return err
 	}
 
-	go cfront.RemoveDistribution(release.Id, client)
+	u.Step(terminal.StatusOK, "Removed DNS records for "+strings.Join(aliases, ", "))
+
+	return nil
+}
+
+// droppedAliases returns the aliases the distribution no longer serves.
+func droppedAliases(aliases []string, config *types.DistributionConfig) []string {
+	served := map[string]bool{}
+	if config != nil && config.Aliases != nil {
+		for _, alias := range config.Aliases.Items {
+			served[alias] = true
+		}
+	}
+
+	dropped := []string{}
+	for _, alias := range aliases {
+		if !served[alias] {
+			dropped = append(dropped, alias)
+		}
+	}
+
+	return dropped
+}
+
+// retireCanaries retires the canary recorded by the release as well as one
+// started after it, if the distribution still exists. It returns the IDs of
+// the staging distributions left disabled.
+func (rm *ReleaseManager) retireCanaries(
+	ctx context.Context,
+	u terminal.Status,
+	client cfront.CloudfrontAPI,
+	release *Release,
+	exists bool,
+) ([]string, error) {
+	canaries := []*cfront.Canary{}
+	if release.StagingId != "" || release.PolicyId != "" {
+		canaries = append(canaries, &cfront.Canary{StagingId: release.StagingId, PolicyId: release.PolicyId})
+	}
+
+	if exists {
+		live, err := cfront.FindCanary(ctx, client, cfront.NewDistributionFinder(client), release.Id)
+		if err != nil {
+			u.Step(terminal.StatusError, "Could not look up the canary of distribution "+release.Id+": "+err.Error())
+			return nil, err
+		}
+
+		if live != nil && live.PolicyId != release.PolicyId {
+			canaries = append(canaries, live)
+		}
+	}
+
+	staging := []string{}
+
+	for _, canary := range canaries {
+		err := rm.retireCanary(ctx, u, client, release.Id, canary, release.OacId)
+		if err != nil {
+			return nil, err
+		}
+
+		if canary.StagingId != "" {
+			staging = append(staging, canary.StagingId)
+		}
+	}
+
+	return staging, nil
+}
+
+// removeOriginAccessControl deletes the origin access control of a deleted
+// distribution, unless another distribution for the bucket still uses it.
+func (rm *ReleaseManager) removeOriginAccessControl(ctx context.Context, u terminal.Status, client cfront.CloudfrontAPI, id string) error {
+	if id == "" {
+		return nil
+	}
 
-	u.Step(terminal.StatusOK, "Scheduled distribution for deletion")
+	err := cfront.RemoveOriginAccessControl(ctx, client, id)
+	if err != nil && !cfront.OriginAccessControlInUse(err) && !cfront.OriginAccessControlNotFound(err) {
+		u.Step(terminal.StatusError, "Could not delete origin access control: "+err.Error())
+		return err
+	}
 
 	return nil
 }
+
+// resumeDeletions deletes the distributions of the bucket earlier destroys
+// left disabled. Failures are only reported, they are retried on the next run.
+func (rm *ReleaseManager) resumeDeletions(ctx context.Context, u terminal.Status, client cfront.CloudfrontAPI, finder *cfront.DistributionFinder, bucket string) {
+	// releases used to be recorded without the bucket
+	if bucket == "" {
+		return
+	}
+
+	deleted, err := cfront.ResumePendingDeletions(ctx, client, finder, bucket)
+	if len(deleted) > 0 {
+		u.Step(terminal.StatusOK, "Deleted previously disabled distributions: "+strings.Join(deleted, ", "))
+	}
+
+	if err != nil {
+		u.Step(terminal.StatusWarn, "Could not delete previously disabled distributions: "+err.Error())
+	}
+}
+
+func (rm *ReleaseManager) deleteTimeout() time.Duration {
+	if rm.config.DeleteTimeout == "" {
+		return DefaultDeleteTimeout
+	}
+
+	// validated in ConfigSet
+	timeout, _ := time.ParseDuration(rm.config.DeleteTimeout)
+
+	return timeout
+}
+
+// clearReleased removes the record of the deployment the distribution
+// served, unless a later release recorded another one. A failure only keeps
+// the deployment around, so it is not fatal.
+func (rm *ReleaseManager) clearReleased(ctx context.Context, u terminal.Status, client platform.S3BucketAPI, release *Release) {
+	if release.Prefix == "" || release.Bucket == "" {
+		return
+	}
+
+	prefix, _, err := platform.ReleasedPrefix(ctx, client, release.Bucket)
+	if err == nil && prefix != release.Prefix {
+		return
+	}
+
+	if err == nil {
+		err = platform.ClearReleased(ctx, client, release.Bucket)
+	}
+	if err != nil {
+		u.Step(terminal.StatusWarn, fmt.Sprintf("Could not clear the released deployment of %v, %v is kept: %v", release.Bucket, release.Prefix, err.Error()))
+	}
+}
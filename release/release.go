@@ -2,24 +2,88 @@ package release
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"strconv"
+	"strings"
+	"time"
 
-	"github.com/aws/aws-sdk-go-v2/config"
 	"github.com/aws/aws-sdk-go-v2/service/cloudfront"
+	"github.com/aws/aws-sdk-go-v2/service/cloudfront/types"
 	"github.com/hashicorp/waypoint-plugin-sdk/component"
 	"github.com/hashicorp/waypoint-plugin-sdk/terminal"
+	"github.com/pilot-framework/aws-cloudfront-waypoint-plugin/awsclient"
 	"github.com/pilot-framework/aws-cloudfront-waypoint-plugin/cfront"
+	"github.com/pilot-framework/aws-cloudfront-waypoint-plugin/creds"
 	"github.com/pilot-framework/aws-cloudfront-waypoint-plugin/platform"
+	"github.com/pilot-framework/aws-cloudfront-waypoint-plugin/r53"
 )
 
 func (r *Release) URL() string { return r.Url }
 
+// Clients are the AWS APIs a release and its destroy use
+type Clients struct {
+	Cloudfront cfront.CloudfrontAPI
+	// ACM in the region of CloudFront certificates
+	Certificates cfront.CertificateAPI
+	Route53      r53.Route53API
+	// S3 in the region of the bucket
+	S3 platform.S3BucketAPI
+}
+
 var _ component.Release = (*Release)(nil)
 
 type ReleaseConfig struct {
 	// This is the Origin Path that the CDN will treat as `/`
 	// default is a 1-1 forward to `/`
 	Root string `hcl:"root,optional"`
+
+	// Custom domain names for the distribution, the first one is the release URL
+	Aliases []string `hcl:"aliases,optional"`
+	// ACM certificate in us-east-1 for the aliases. If omitted an issued
+	// certificate covering all aliases is looked up
+	CertificateArn string `hcl:"certificate_arn,optional"`
+	// Minimum TLS version for viewers, defaults to TLSv1.2_2021
+	MinimumProtocolVersion string `hcl:"minimum_protocol_version,optional"`
+	// Route 53 hosted zone in which alias records for the aliases are managed
+	HostedZoneId string `hcl:"hosted_zone_id,optional"`
+
+	// Paths invalidated when an existing distribution is released, defaults
+	// to the files changed by the deployment or /*
+	InvalidationPaths []string `hcl:"invalidation_paths,optional"`
+	// Wait until the invalidation has completed
+	WaitForInvalidation bool `hcl:"wait_for_invalidation,optional"`
+	// Do not invalidate cached files on release
+	SkipInvalidation bool `hcl:"skip_invalidation,optional"`
+
+	// Release through a staging distribution receiving part of the traffic,
+	// only for immutable deployments
+	Canary *CanaryConfig `hcl:"canary,block"`
+
+	// How long destroy waits for the disabled distribution to deploy before
+	// leaving its deletion to the next release or destroy, defaults to 20m
+	DeleteTimeout string `hcl:"delete_timeout,optional"`
+
+	// CloudFront compatible endpoint to use instead of AWS, e.g. LocalStack
+	CloudfrontEndpoint string `hcl:"cloudfront_endpoint,optional"`
+	// S3 compatible endpoint for the bucket policy set with oac access
+	S3Endpoint string `hcl:"s3_endpoint,optional"`
+	// Address buckets in the URL path instead of the host name
+	ForcePathStyle bool `hcl:"force_path_style,optional"`
+
+	// Named profile from the shared AWS config files
+	Profile string `hcl:"profile,optional"`
+	// IAM role to assume with the profile's credentials, e.g. in another account
+	RoleArn string `hcl:"role_arn,optional"`
+	// External ID the trust policy of the role requires
+	ExternalId string `hcl:"external_id,optional"`
+	// Session name of the assumed role, defaults to pilot-waypoint
+	SessionName string `hcl:"session_name,optional"`
+
+	// Retry mode of the AWS clients, "standard" (default) or "adaptive"
+	RetryMode string `hcl:"retry_mode,optional"`
+	// Attempts per request including the first, defaults to 3
+	MaxAttempts int `hcl:"max_attempts,optional"`
 }
 
 type ReleaseManager struct {
@@ -33,17 +97,88 @@ func (rm *ReleaseManager) Config() (interface{}, error) {
 
 // Implement ConfigurableNotify
 func (rm *ReleaseManager) ConfigSet(config interface{}) error {
-	_, ok := config.(*ReleaseConfig)
+	c, ok := config.(*ReleaseConfig)
 	if !ok {
 		// The Waypoint SDK should ensure this never gets hit
 		return fmt.Errorf("expected *ReleaseConfig as parameter")
 	}
 
 	// validate the config
+	if len(c.Aliases) == 0 && (c.CertificateArn != "" || c.MinimumProtocolVersion != "" || c.HostedZoneId != "") {
+		return fmt.Errorf("certificate_arn, minimum_protocol_version and hosted_zone_id require aliases")
+	}
+
+	if c.MinimumProtocolVersion != "" && !validProtocolVersion(c.MinimumProtocolVersion) {
+		return fmt.Errorf("unsupported minimum_protocol_version, got: %v", c.MinimumProtocolVersion)
+	}
+
+	if c.Canary != nil {
+		if err := validateCanary(c.Canary); err != nil {
+			return err
+		}
+	}
+
+	if c.DeleteTimeout != "" {
+		timeout, err := time.ParseDuration(c.DeleteTimeout)
+		if err != nil || timeout < 0 {
+			return fmt.Errorf("delete_timeout must be a non-negative duration like 20m, got: %v", c.DeleteTimeout)
+		}
+	}
+
+	if err := awsclient.ValidateEndpoint("cloudfront_endpoint", c.CloudfrontEndpoint); err != nil {
+		return err
+	}
+
+	if err := awsclient.ValidateEndpoint("s3_endpoint", c.S3Endpoint); err != nil {
+		return err
+	}
+
+	if err := clientSettings(c, "").Validate(); err != nil {
+		return err
+	}
 
 	return nil
 }
 
+// clientSettings configures the AWS clients for the bucket region of the
+// deployment, CloudFront itself is global
+func clientSettings(c *ReleaseConfig, region string) awsclient.Settings {
+	return awsclient.Settings{
+		Region: region,
+		Credentials: creds.Options{
+			Profile:     c.Profile,
+			RoleArn:     c.RoleArn,
+			ExternalId:  c.ExternalId,
+			SessionName: c.SessionName,
+		},
+		RetryMode:          c.RetryMode,
+		MaxAttempts:        c.MaxAttempts,
+		S3Endpoint:         c.S3Endpoint,
+		ForcePathStyle:     c.ForcePathStyle,
+		CloudfrontEndpoint: c.CloudfrontEndpoint,
+	}
+}
+
+// newClients creates the clients of a release from the factory
+func newClients(f *awsclient.Factory) *Clients {
+	return &Clients{
+		Cloudfront:   f.CloudFront(),
+		Certificates: f.Certificates(),
+		Route53:      f.Route53(),
+		S3:           f.S3(),
+	}
+}
+
+func validProtocolVersion(version string) bool {
+	for _, v := range types.MinimumProtocolVersion("").Values() {
+		if string(v) == version {
+			return true
+		}
+	}
+
+	return false
+}
+
 // Implement Builder
 func (rm *ReleaseManager) ReleaseFunc() interface{} {
 	// return a function which will be called by Waypoint
@@ -61,57 +196,129 @@ func (rm *ReleaseManager) ReleaseFunc() interface{} {
 // If an error is returned, Waypoint stops the execution flow and
 // returns an error to the user.
 func (rm *ReleaseManager) release(ctx context.Context, ui terminal.UI, target *platform.Deployment) (*Release, error) {
+	f, err := awsclient.New(ctx, clientSettings(&rm.config, target.Region))
+	if err != nil {
+		ui.Output("AWS configuration error, "+err.Error(), terminal.WithErrorStyle())
+		return nil, err
+	}
+
+	if target.AccountId != "" && rm.config.CloudfrontEndpoint == "" {
+		account, err := f.AccountId(ctx)
+		if err != nil {
+			ui.Output("Could not identify the AWS account, "+err.Error(), terminal.WithErrorStyle())
+			return nil, err
+		}
+
+		if account != target.AccountId {
+			err = fmt.Errorf("the bucket was deployed to account %v, but the release credentials belong to %v", target.AccountId, account)
+			ui.Output(err.Error(), terminal.WithErrorStyle())
+			return nil, err
+		}
+	}
+
+	return rm.Release(ctx, ui, target, newClients(f))
+}
+
+// Release creates or updates the distribution serving the deployment
+// through clients.
+func (rm *ReleaseManager) Release(ctx context.Context, ui terminal.UI, target *platform.Deployment, clients *Clients) (*Release, error) {
 	u := ui.Status()
 	defer u.Close()
 	u.Step("", "--- Configuring AWS Cloudfront ---")
 
-	cfg, err := config.LoadDefaultConfig(context.TODO())
-	if err != nil {
-		u.Step(terminal.StatusError, "AWS configuration error, "+err.Error())
+	client := clients.Cloudfront
+
+	u.Update("Searching for distribution belonging to " + target.Bucket + "...")
+
+	finder := cfront.NewDistributionFinder(client)
+	rm.resumeDeletions(ctx, u, client, finder, target.Bucket)
+	distExists := true
+	distId := ""
+
+	existing, err := finder.Find(ctx, target.Bucket)
+	if errors.Is(err, cfront.ErrDistributionNotFound) {
+		distExists = false
+	} else if err != nil {
+		u.Step(terminal.StatusError, "Error searching distributions: "+err.Error())
 		return nil, err
+	} else {
+		distId = *existing.Id
 	}
 
-	client := cloudfront.NewFromConfig(cfg)
-
-	u.Update("Checking for existing distributions...")
-	dists, err := cfront.GetAllDistributions(context.TODO(), client, &cloudfront.ListDistributionsInput{})
+	prefix, err := rm.servedPrefix(ctx, u, clients.S3, target)
+	if err != nil {
+		return nil, err
+	}
 
+	plan, err := rm.planCanary(ctx, u, clients, finder, target, distId, prefix)
 	if err != nil {
-		u.Step(terminal.StatusError, "Error retrieving distributions")
 		return nil, err
 	}
 
-	u.Update("Searching for distribution belonging to " + target.Bucket + "...")
+	// the distribution takes over the configuration of the canary, so that
+	// reconciling below finds nothing left to change
+	if plan.Action == CanaryPromote {
+		u.Update("Promoting canary " + prefix + "...")
 
-	distExists := false
+		_, err = cfront.PromoteCanary(ctx, client, distId, plan.Active)
+		if err != nil {
+			u.Step(terminal.StatusError, "Could not promote canary: "+err.Error())
+			return nil, err
+		}
 
-	for _, v := range dists.DistributionList.Items {
-		tagInput := &cloudfront.ListTagsForResourceInput{
-			Resource: v.ARN,
+		u.Step(terminal.StatusOK, fmt.Sprintf("Promoted canary, distribution %v serves %v", distId, prefix))
+	}
+
+	if (plan.Action == CanaryPromote || plan.Action == CanaryAbort) && plan.Active != nil {
+		err = rm.retireCanary(ctx, u, client, distId, plan.Active, "")
+		if err != nil {
+			return nil, err
 		}
+	}
+
+	r := &Release{}
+	opts := cfront.DistributionOptions{
+		Bucket:                 target.Bucket,
+		Region:                 target.Region,
+		Root:                   cfront.OriginPath(plan.PrimaryPrefix, rm.config.Root),
+		Aliases:                rm.config.Aliases,
+		CertificateArn:         rm.config.CertificateArn,
+		MinimumProtocolVersion: rm.config.MinimumProtocolVersion,
+	}
+
+	if len(opts.Aliases) > 0 && opts.CertificateArn == "" {
+		u.Update("Looking up certificate for " + strings.Join(opts.Aliases, ", ") + "...")
 
-		tags, err := cfront.GetDistributionTags(context.TODO(), client, tagInput)
+		opts.CertificateArn, err = cfront.FindCertificate(ctx, clients.Certificates, opts.Aliases)
 		if err != nil {
-			u.Step(terminal.StatusError, fmt.Sprintf("Error retrieving tags for %v", *v.ARN))
+			u.Step(terminal.StatusError, "Could not find a certificate: "+err.Error())
 			return nil, err
 		}
 
-		for _, tag := range tags.Tags.Items {
-			if *tag.Key == "bucket" && *tag.Value == target.Bucket {
-				distExists = true
-				break
-			}
+		u.Step(terminal.StatusOK, "Using certificate "+opts.CertificateArn)
+	}
+
+	if target.Access == platform.AccessOAC {
+		u.Update("Configuring origin access control...")
+
+		opts.OriginAccessControlId, err = cfront.EnsureOriginAccessControl(ctx, client, target.Bucket)
+		if err != nil {
+			u.Step(terminal.StatusError, "Could not create origin access control for "+target.Bucket)
+			return nil, err
 		}
+
+		r.OacId = opts.OriginAccessControlId
 	}
 
-	r := &Release{}
+	var dist *types.Distribution
+	var etag string
 
 	if !distExists {
 		u.Step("", fmt.Sprintf("Could not find distribution belonging to %v, creating new distribution...", target.Bucket))
 
-		newDistInput := cfront.FormatDistributionInput(target.Bucket, target.Region, rm.config.Root)
+		newDistInput := cfront.FormatDistributionInput(opts)
 
-		newDist, err := cfront.CreateDistribution(context.TODO(), client, newDistInput)
+		newDist, err := cfront.CreateDistribution(ctx, client, newDistInput)
 		if err != nil {
 			u.Step(terminal.StatusError, fmt.Sprintf("Error creating distribution: %v", err.Error()))
 
@@ -125,13 +332,264 @@ func (rm *ReleaseManager) release(ctx context.Context, ui terminal.UI, target *p
 				*newDist.Distribution.DomainName,
 			))
 
-		r.Url = "https://" + *newDist.Distribution.DomainName
-		r.Id = *newDist.Distribution.Id
-		r.Etag = *newDist.ETag
-		r.Origin = fmt.Sprintf("pilot-origin-%v", target.Bucket)
+		dist = newDist.Distribution
+		etag = *newDist.ETag
 	} else {
 		u.Step(terminal.StatusOK, fmt.Sprintf("Found an existing distribution for %v", target.Bucket))
+
+		dist, etag, err = rm.reconcile(ctx, u, client, distId, opts)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	distArn := *dist.ARN
+	distDomain := *dist.DomainName
+
+	r.Id = *dist.Id
+	r.Etag = etag
+	r.Generation = strconv.FormatInt(time.Now().UnixNano(), 10)
+	r.Origin = fmt.Sprintf("pilot-origin-%v", target.Bucket)
+	r.DomainName = distDomain
+	r.Aliases = opts.Aliases
+	r.Region = target.Region
+	r.Bucket = target.Bucket
+	r.Prefix = plan.PrimaryPrefix
+	r.Url = "https://" + distDomain
+	if len(opts.Aliases) > 0 {
+		r.Url = "https://" + opts.Aliases[0]
+	}
+
+	// destroying an earlier release of the distribution must leave it to this one
+	err = cfront.TagRelease(ctx, client, distArn, r.Generation)
+	if err != nil {
+		u.Step(terminal.StatusError, "Could not tag distribution "+r.Id+": "+err.Error())
+		return nil, err
+	}
+
+	readers := []string{distArn}
+
+	if plan.Action == CanaryStart {
+		canary, err := rm.stageCanary(ctx, u, client, r.Id, r.Origin, plan)
+		if err != nil {
+			return nil, err
+		}
+
+		r.StagingId = canary.StagingId
+		r.PolicyId = canary.PolicyId
+		r.CanaryPrefix = plan.StagedPrefix
+		readers = append(readers, canary.StagingArn)
+
+		u.Step(terminal.StatusOK, fmt.Sprintf("Canary %v is served by staging distribution %v", plan.StagedPrefix, canary.StagingId))
+	}
+
+	if target.Access == platform.AccessOAC {
+		u.Update("Granting the distribution read access to " + target.Bucket + "...")
+
+		err = platform.PutDistributionBucketPolicy(ctx, target.Bucket, readers, clients.S3)
+		if err != nil {
+			u.Step(terminal.StatusError, "Could not set bucket policy for "+target.Bucket)
+			return nil, err
+		}
+
+		u.Step(terminal.StatusOK, "Bucket policy only allows reads from the distribution")
+	}
+
+	if plan.PrimaryPrefix != "" {
+		err = platform.MarkReleased(ctx, clients.S3, target.Bucket, plan.PrimaryPrefix, plan.StagedPrefix)
+		if err != nil {
+			u.Step(terminal.StatusError, "Could not record the released deployment in "+target.Bucket)
+			return nil, err
+		}
+	}
+
+	if rm.config.HostedZoneId != "" {
+		u.Update("Updating DNS records in hosted zone " + rm.config.HostedZoneId + "...")
+
+		err = r53.UpsertAliases(ctx, clients.Route53, rm.config.HostedZoneId, rm.config.Aliases, distDomain)
+		if err != nil {
+			u.Step(terminal.StatusError, "Could not update DNS records: "+err.Error())
+			return nil, err
+		}
+
+		r.HostedZoneId = rm.config.HostedZoneId
+		u.Step(terminal.StatusOK, "DNS records point "+strings.Join(rm.config.Aliases, ", ")+" to "+distDomain)
+	}
+
+	if rm.config.SkipInvalidation {
+		return r, nil
+	}
+
+	// a new distribution has nothing cached yet, neither has a new canary,
+	// while a started or aborted canary leaves the distribution as it was
+	switch {
+	case plan.Action == CanaryStart && plan.Active != nil:
+		r.InvalidationId, err = rm.switchAndInvalidate(ctx, u, client, r.StagingId, plan.StagedPrefix, target)
+	case plan.Action == CanaryStart || plan.Action == CanaryAbort:
+	case distExists:
+		r.InvalidationId, err = rm.switchAndInvalidate(ctx, u, client, distId, plan.PrimaryPrefix, target)
+	}
+	if err != nil {
+		return nil, err
 	}
 
 	return r, nil
 }
+
+// switchAndInvalidate waits for a distribution switched to the deployment
+// prefix to deploy, as edges still on the old origin path would cache the
+// previous deployment again right after the invalidation, and invalidates it.
+func (rm *ReleaseManager) switchAndInvalidate(
+	ctx context.Context,
+	u terminal.Status,
+	client cfront.CloudfrontAPI,
+	distId string,
+	prefix string,
+	target *platform.Deployment,
+) (string, error) {
+	if prefix != "" {
+		err := cfront.PollStatus(ctx, client, distId, cfront.NewWaiter(cfront.DeployTimeout, func(status string) {
+			u.Update(fmt.Sprintf("Waiting for distribution %v to switch to %v (%v)...", distId, prefix, status))
+		}))
+		if err != nil {
+			u.Step(terminal.StatusError, "Distribution did not deploy: "+err.Error())
+			return "", err
+		}
+
+		u.Step(terminal.StatusOK, fmt.Sprintf("Distribution %v serves %v", distId, prefix))
+	}
+
+	return rm.invalidate(ctx, u, client, distId, target)
+}
+
+// servedPrefix returns the deployment prefix the distribution is switched
+// to. Releasing an earlier immutable deployment rolls back to it, which fails
+// if it was garbage collected.
+func (rm *ReleaseManager) servedPrefix(
+	ctx context.Context,
+	u terminal.Status,
+	client platform.S3BucketAPI,
+	target *platform.Deployment,
+) (string, error) {
+	prefix := target.Prefix
+	if prefix == "" {
+		return "", nil
+	}
+
+	u.Update("Checking deployment " + prefix + " is retained...")
+
+	exists, err := platform.PrefixExists(ctx, client, target.Bucket, prefix)
+	if err != nil {
+		u.Step(terminal.StatusError, "Could not list deployment "+prefix+": "+err.Error())
+		return "", err
+	}
+
+	if !exists {
+		err = fmt.Errorf("deployment %v is no longer retained in %v", prefix, target.Bucket)
+		u.Step(terminal.StatusError, err.Error())
+		return "", err
+	}
+
+	return prefix, nil
+}
+
+// reconcile brings the configuration of an existing distribution in line with
+// opts and returns the distribution along with its current ETag.
+func (rm *ReleaseManager) reconcile(
+	ctx context.Context,
+	u terminal.Status,
+	client cfront.CloudfrontAPI,
+	id string,
+	opts cfront.DistributionOptions,
+) (*types.Distribution, string, error) {
+	u.Update("Comparing distribution configuration...")
+
+	current, err := cfront.GetDistributionConfig(ctx, client, &cloudfront.GetDistributionConfigInput{
+		Id: &id,
+	})
+	if err != nil {
+		u.Step(terminal.StatusError, "Error retrieving distribution configuration")
+		return nil, "", err
+	}
+
+	desired := cfront.FormatDistributionInput(opts).DistributionConfigWithTags.DistributionConfig
+	changes := cfront.ReconcileDistributionConfig(current.DistributionConfig, desired)
+
+	if len(changes) == 0 {
+		existing, err := cfront.GetDistribution(ctx, client, &cloudfront.GetDistributionInput{
+			Id: &id,
+		})
+		if err != nil {
+			u.Step(terminal.StatusError, "Error retrieving distribution")
+			return nil, "", err
+		}
+
+		u.Step(terminal.StatusOK, "Distribution configuration is up to date")
+
+		return existing.Distribution, *existing.ETag, nil
+	}
+
+	u.Update("Updating distribution " + strings.Join(changes, ", ") + "...")
+
+	updated, err := cfront.UpdateDistribution(ctx, client, &cloudfront.UpdateDistributionInput{
+		Id:                 &id,
+		IfMatch:            current.ETag,
+		DistributionConfig: current.DistributionConfig,
+	})
+	if err != nil {
+		u.Step(terminal.StatusError, fmt.Sprintf("Error updating distribution: %v", err.Error()))
+		return nil, "", err
+	}
+
+	u.Step(terminal.StatusOK, fmt.Sprintf("Updated distribution %v: %v", id, strings.Join(changes, ", ")))
+
+	return updated.Distribution, *updated.ETag, nil
+}
+
+// invalidate clears the files changed by the deployment from the edge
+// caches of the distribution and returns the invalidation ID.
+func (rm *ReleaseManager) invalidate(
+	ctx context.Context,
+	u terminal.Status,
+	client cfront.CloudfrontAPI,
+	distId string,
+	target *platform.Deployment,
+) (string, error) {
+	paths := rm.config.InvalidationPaths
+	if len(paths) == 0 {
+		// immutable deployments change every path, older ones did not record it
+		paths = []string{cfront.InvalidateAll}
+		if target.ChangedKeysKnown {
+			paths = cfront.InvalidationPaths(target.ChangedKeys, rm.config.Root)
+		}
+	}
+
+	if len(paths) == 0 {
+		u.Step(terminal.StatusOK, "No served files changed, skipping invalidation")
+		return "", nil
+	}
+
+	u.Update(fmt.Sprintf("Invalidating %d path(s)...", len(paths)))
+
+	id, err := cfront.Invalidate(ctx, client, distId, paths)
+	if err != nil {
+		u.Step(terminal.StatusError, "Could not create invalidation: "+err.Error())
+		return "", err
+	}
+
+	if rm.config.WaitForInvalidation {
+		err = cfront.WaitForInvalidation(ctx, client, distId, id, cfront.NewWaiter(cfront.InvalidationTimeout, func(status string) {
+			u.Update(fmt.Sprintf("Waiting for invalidation %v (%v)...", id, status))
+		}))
+		if err != nil {
+			u.Step(terminal.StatusError, "Invalidation did not complete: "+err.Error())
+			return "", err
+		}
+
+		u.Step(terminal.StatusOK, fmt.Sprintf("Invalidation %v completed for %v", id, strings.Join(paths, ", ")))
+	} else {
+		u.Step(terminal.StatusOK, fmt.Sprintf("Created invalidation %v for %v", id, strings.Join(paths, ", ")))
+	}
+
+	return id, nil
+}
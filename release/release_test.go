@@ -0,0 +1,436 @@
+package release_test
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	acmtypes "github.com/aws/aws-sdk-go-v2/service/acm/types"
+	"github.com/aws/aws-sdk-go-v2/service/cloudfront"
+	"github.com/hashicorp/waypoint-plugin-sdk/component"
+	"github.com/hashicorp/waypoint-plugin-sdk/terminal"
+	"github.com/pilot-framework/aws-cloudfront-waypoint-plugin/cfront"
+	"github.com/pilot-framework/aws-cloudfront-waypoint-plugin/fakes"
+	"github.com/pilot-framework/aws-cloudfront-waypoint-plugin/platform"
+	"github.com/pilot-framework/aws-cloudfront-waypoint-plugin/release"
+)
+
+// env is a site deployed to and released from fake AWS services
+type env struct {
+	t       *testing.T
+	ctx     context.Context
+	ui      terminal.UI
+	dir     string
+	s3      *fakes.S3
+	cf      *fakes.CloudFront
+	acm     *fakes.ACM
+	route53 *fakes.Route53
+	clients *release.Clients
+	p       *platform.Platform
+}
+
+func newEnv(t *testing.T, config platform.PlatformConfig) *env {
+	ctx := context.Background()
+	e := &env{
+		t:       t,
+		ctx:     ctx,
+		ui:      terminal.ConsoleUI(ctx),
+		dir:     t.TempDir(),
+		s3:      fakes.NewS3(),
+		cf:      fakes.NewCloudFront(),
+		acm:     fakes.NewACM(),
+		route53: fakes.NewRoute53(),
+	}
+	e.clients = &release.Clients{Cloudfront: e.cf, Certificates: e.acm, Route53: e.route53, S3: e.s3}
+
+	config.Region = "us-east-1"
+	config.BucketName = "site"
+	config.BuildDir = e.dir
+
+	e.p = &platform.Platform{}
+	c, _ := e.p.Config()
+	*c.(*platform.PlatformConfig) = config
+	if err := e.p.ConfigSet(c); err != nil {
+		t.Fatal(err)
+	}
+
+	return e
+}
+
+func (e *env) write(name string, content string) {
+	e.t.Helper()
+
+	if err := os.WriteFile(filepath.Join(e.dir, name), []byte(content), 0644); err != nil {
+		e.t.Fatal(err)
+	}
+}
+
+func (e *env) deploy(id string) *platform.Deployment {
+	e.t.Helper()
+
+	d, err := e.p.Deploy(e.ctx, e.ui, &component.Source{App: "web", Path: e.dir}, &component.DeploymentConfig{Id: id}, e.s3)
+	if err != nil {
+		e.t.Fatal(err)
+	}
+
+	return d
+}
+
+func newReleaseManager(t *testing.T, config release.ReleaseConfig) *release.ReleaseManager {
+	t.Helper()
+
+	rm := &release.ReleaseManager{}
+	c, _ := rm.Config()
+	*c.(*release.ReleaseConfig) = config
+	if err := rm.ConfigSet(c); err != nil {
+		t.Fatal(err)
+	}
+
+	return rm
+}
+
+func (e *env) release(config release.ReleaseConfig, d *platform.Deployment) (*release.Release, error) {
+	return newReleaseManager(e.t, config).Release(e.ctx, e.ui, d, e.clients)
+}
+
+// originPath returns the origin path the distribution with the given ID serves
+func (e *env) originPath(id string) string {
+	return aws.ToString(e.cf.Distribution(id).Config.Origins.Items[0].OriginPath)
+}
+
+func (e *env) invalidationPaths(distId string, id string) []string {
+	return e.cf.Distribution(distId).Invalidations[id].InvalidationBatch.Paths.Items
+}
+
+func TestDeployReleaseDestroy(t *testing.T) {
+	e := newEnv(t, platform.PlatformConfig{Access: platform.AccessOAC, Sync: true})
+	e.write("index.html", "<html></html>")
+	e.write("app.js", "v1")
+
+	e.route53.AddHostedZone("Z1")
+	e.acm.AddCertificate(acmtypes.CertificateStatusIssued, "other.example.org")
+	certArn := e.acm.AddCertificate(acmtypes.CertificateStatusIssued, "example.com", "*.example.com")
+
+	config := release.ReleaseConfig{
+		Aliases:      []string{"www.example.com"},
+		HostedZoneId: "Z1",
+	}
+
+	r, err := e.release(config, e.deploy("01A"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if r.Id == "" || r.Etag == "" || r.OacId == "" {
+		t.Fatalf("release = %+v", r)
+	}
+	if r.URL() != "https://www.example.com" {
+		t.Errorf("URL = %v", r.URL())
+	}
+	if r.InvalidationId != "" {
+		t.Errorf("new distribution was invalidated: %v", r.InvalidationId)
+	}
+
+	dist := e.cf.Distribution(r.Id)
+	if got := dist.Config.Aliases.Items; !reflect.DeepEqual(got, []string{"www.example.com"}) {
+		t.Errorf("aliases = %v", got)
+	}
+	if got := aws.ToString(dist.Config.ViewerCertificate.ACMCertificateArn); got != certArn {
+		t.Errorf("certificate = %v, want %v", got, certArn)
+	}
+	if !strings.Contains(e.s3.Bucket("site").Policy, dist.ARN) {
+		t.Errorf("bucket policy does not grant %v: %v", dist.ARN, e.s3.Bucket("site").Policy)
+	}
+
+	records := e.route53.Records("Z1")
+	if len(records) != 2 {
+		t.Fatalf("records = %+v, want A and AAAA", records)
+	}
+	if !aws.ToBool(dist.Config.IsIPV6Enabled) {
+		t.Error("IPv6 is not enabled for the AAAA records")
+	}
+	for _, record := range records {
+		if aws.ToString(record.AliasTarget.DNSName) != dist.DomainName {
+			t.Errorf("%v record points to %v", record.Type, aws.ToString(record.AliasTarget.DNSName))
+		}
+	}
+
+	// a changed file is invalidated on its own
+	e.write("app.js", "v2")
+
+	r, err = e.release(config, e.deploy("01B"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.InvalidationId == "" {
+		t.Fatal("changed deployment was not invalidated")
+	}
+	if got := e.invalidationPaths(r.Id, r.InvalidationId); !reflect.DeepEqual(got, []string{"/app.js"}) {
+		t.Errorf("invalidated %v, want /app.js", got)
+	}
+
+	// nothing changed, nothing to invalidate
+	r, err = e.release(config, e.deploy("01C"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.InvalidationId != "" {
+		t.Errorf("unchanged deployment was invalidated: %v", e.invalidationPaths(r.Id, r.InvalidationId))
+	}
+
+	if err := newReleaseManager(t, config).Destroy(e.ctx, e.ui, r, e.clients); err != nil {
+		t.Fatal(err)
+	}
+
+	if ids := e.cf.DistributionIds(); len(ids) != 0 {
+		t.Errorf("distributions left: %v", ids)
+	}
+	if ids := e.cf.OriginAccessControlIds(); len(ids) != 0 {
+		t.Errorf("origin access controls left: %v", ids)
+	}
+	if records := e.route53.Records("Z1"); len(records) != 0 {
+		t.Errorf("records left: %+v", records)
+	}
+
+	if err := e.p.Destroy(e.ctx, e.ui, &platform.Deployment{Bucket: "site"}, e.s3); err != nil {
+		t.Fatal(err)
+	}
+	if e.s3.Bucket("site") != nil {
+		t.Error("bucket was not deleted")
+	}
+}
+
+func TestReleaseRequiresCertificate(t *testing.T) {
+	e := newEnv(t, platform.PlatformConfig{})
+	e.write("index.html", "<html></html>")
+
+	e.acm.AddCertificate(acmtypes.CertificateStatusPendingValidation, "www.example.com")
+
+	_, err := e.release(release.ReleaseConfig{Aliases: []string{"www.example.com"}}, e.deploy("01A"))
+	if err == nil || !strings.Contains(err.Error(), "no issued certificate") {
+		t.Errorf("err = %v, want no issued certificate", err)
+	}
+	if ids := e.cf.DistributionIds(); len(ids) != 0 {
+		t.Errorf("distributions created without certificate: %v", ids)
+	}
+}
+
+func TestImmutableReleaseAndRollback(t *testing.T) {
+	e := newEnv(t, platform.PlatformConfig{Access: platform.AccessOAC, Immutable: true})
+	e.write("index.html", "v1")
+	a := e.deploy("01A")
+	e.write("index.html", "v2")
+	b := e.deploy("01B")
+
+	r, err := e.release(release.ReleaseConfig{}, a)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := e.originPath(r.Id); got != "/deployments/01A" || r.Prefix != "deployments/01A/" {
+		t.Errorf("release serves %v, recorded %v", got, r.Prefix)
+	}
+
+	r, err = e.release(release.ReleaseConfig{}, b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := e.originPath(r.Id); got != "/deployments/01B" {
+		t.Errorf("origin path = %v", got)
+	}
+	if got := e.invalidationPaths(r.Id, r.InvalidationId); !reflect.DeepEqual(got, []string{"/*"}) {
+		t.Errorf("switching deployments invalidated %v, want /*", got)
+	}
+
+	// rolling back is releasing the earlier deployment again
+	r, err = e.release(release.ReleaseConfig{}, a)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := e.originPath(r.Id); got != "/deployments/01A" || r.Prefix != "deployments/01A/" {
+		t.Errorf("rollback serves %v, recorded %v", got, r.Prefix)
+	}
+
+	if err := platform.DeletePrefix(e.ctx, e.s3, "site", b.Prefix); err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = e.release(release.ReleaseConfig{}, b)
+	if err == nil || !strings.Contains(err.Error(), "no longer retained") {
+		t.Errorf("err = %v, want the deployment to be no longer retained", err)
+	}
+}
+
+func TestCanaryRelease(t *testing.T) {
+	e := newEnv(t, platform.PlatformConfig{Access: platform.AccessOAC, Immutable: true})
+	e.write("index.html", "v1")
+	a := e.deploy("01A")
+	e.write("index.html", "v2")
+	b := e.deploy("01B")
+
+	primary, err := e.release(release.ReleaseConfig{}, a)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	r, err := e.release(release.ReleaseConfig{Canary: &release.CanaryConfig{Weight: 0.1}}, b)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if r.StagingId == "" || r.PolicyId == "" || r.CanaryPrefix != "deployments/01B/" || r.Prefix != "deployments/01A/" {
+		t.Fatalf("canary release = %+v", r)
+	}
+	if got := e.originPath(primary.Id); got != "/deployments/01A" {
+		t.Errorf("primary serves %v during the canary", got)
+	}
+	if got := e.originPath(r.StagingId); got != "/deployments/01B" {
+		t.Errorf("staging serves %v", got)
+	}
+	if got := aws.ToString(e.cf.Distribution(primary.Id).Config.ContinuousDeploymentPolicyId); got != r.PolicyId {
+		t.Errorf("primary policy = %v, want %v", got, r.PolicyId)
+	}
+	if !strings.Contains(e.s3.Bucket("site").Policy, e.cf.Distribution(r.StagingId).ARN) {
+		t.Error("bucket policy does not grant the staging distribution")
+	}
+
+	if _, err := e.release(release.ReleaseConfig{}, b); err == nil {
+		t.Error("released without promoting the canary")
+	}
+
+	r, err = e.release(release.ReleaseConfig{Canary: &release.CanaryConfig{Action: release.CanaryPromote}}, b)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got := e.originPath(primary.Id); got != "/deployments/01B" {
+		t.Errorf("primary serves %v after promotion", got)
+	}
+	if ids := e.cf.ContinuousDeploymentPolicyIds(); len(ids) != 0 {
+		t.Errorf("policies left after promotion: %v", ids)
+	}
+
+	// the disabled staging distribution is deleted by the destroy
+	if err := newReleaseManager(t, release.ReleaseConfig{}).Destroy(e.ctx, e.ui, r, e.clients); err != nil {
+		t.Fatal(err)
+	}
+	if ids := e.cf.DistributionIds(); len(ids) != 0 {
+		t.Errorf("distributions left: %v", ids)
+	}
+}
+
+func TestDestroyEarlierRelease(t *testing.T) {
+	for _, config := range []platform.PlatformConfig{
+		{Access: platform.AccessOAC},
+		{Access: platform.AccessOAC, Immutable: true},
+	} {
+		e := newEnv(t, config)
+		e.write("index.html", "v1")
+		e.route53.AddHostedZone("Z1")
+		e.acm.AddCertificate(acmtypes.CertificateStatusIssued, "www.example.com")
+
+		releaseConfig := release.ReleaseConfig{Aliases: []string{"www.example.com"}, HostedZoneId: "Z1"}
+
+		older, err := e.release(releaseConfig, e.deploy("01A"))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		e.write("index.html", "v2")
+		newer, err := e.release(releaseConfig, e.deploy("01B"))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if err := newReleaseManager(t, releaseConfig).Destroy(e.ctx, e.ui, older, e.clients); err != nil {
+			t.Fatal(err)
+		}
+
+		if ids := e.cf.DistributionIds(); !reflect.DeepEqual(ids, []string{newer.Id}) {
+			t.Fatalf("immutable %v: distributions = %v, want %v", config.Immutable, ids, newer.Id)
+		}
+		if dist := e.cf.Distribution(newer.Id); !aws.ToBool(dist.Config.Enabled) {
+			t.Errorf("immutable %v: destroying the earlier release disabled the distribution", config.Immutable)
+		}
+		if records := e.route53.Records("Z1"); len(records) != 2 {
+			t.Errorf("immutable %v: records = %+v, want A and AAAA", config.Immutable, records)
+		}
+		if config.Immutable {
+			if prefix, _, _ := platform.ReleasedPrefix(e.ctx, e.s3, "site"); prefix != newer.Prefix {
+				t.Errorf("released prefix = %v, want %v", prefix, newer.Prefix)
+			}
+		}
+
+		if err := newReleaseManager(t, releaseConfig).Destroy(e.ctx, e.ui, newer, e.clients); err != nil {
+			t.Fatal(err)
+		}
+		if ids := e.cf.DistributionIds(); len(ids) != 0 {
+			t.Errorf("immutable %v: distributions left: %v", config.Immutable, ids)
+		}
+	}
+}
+
+func TestDestroyResumedByRelease(t *testing.T) {
+	e := newEnv(t, platform.PlatformConfig{Access: platform.AccessOAC})
+	e.write("index.html", "v1")
+	e.cf.DeployPolls = 2
+
+	config := release.ReleaseConfig{DeleteTimeout: "0s"}
+
+	r, err := e.release(config, e.deploy("01A"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the disabled distribution is still deploying when destroy gives up
+	if err := newReleaseManager(t, config).Destroy(e.ctx, e.ui, r, e.clients); err != nil {
+		t.Fatal(err)
+	}
+
+	dist := e.cf.Distribution(r.Id)
+	if dist == nil {
+		t.Fatal("distribution was deleted before it was disabled")
+	}
+	if aws.ToBool(dist.Config.Enabled) {
+		t.Error("distribution was not disabled")
+	}
+	if dist.Tags[cfront.PendingDeletionTagKey] == "" || dist.Tags[cfront.OriginAccessControlTagKey] != r.OacId {
+		t.Errorf("tags = %v, want the pending deletion and origin access control", dist.Tags)
+	}
+
+	// deployed by the time of the next release, which deletes it along with
+	// its origin access control and creates a new distribution
+	for dist.Status != "Deployed" {
+		if _, err := e.cf.GetDistribution(e.ctx, &cloudfront.GetDistributionInput{Id: &r.Id}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	next, err := e.release(config, e.deploy("01B"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if ids := e.cf.DistributionIds(); !reflect.DeepEqual(ids, []string{next.Id}) || next.Id == r.Id {
+		t.Errorf("distributions = %v, want only the new %v", ids, next.Id)
+	}
+	if ids := e.cf.OriginAccessControlIds(); !reflect.DeepEqual(ids, []string{next.OacId}) || next.OacId == r.OacId {
+		t.Errorf("origin access controls = %v, want only the new %v", ids, next.OacId)
+	}
+}
+
+func TestDestroyReleaseWithoutId(t *testing.T) {
+	ctx := context.Background()
+	cf := fakes.NewCloudFront()
+
+	err := newReleaseManager(t, release.ReleaseConfig{}).Destroy(ctx, terminal.ConsoleUI(ctx), &release.Release{}, &release.Clients{
+		Cloudfront: cf,
+		S3:         fakes.NewS3(),
+	})
+	if err != nil {
+		t.Error(err)
+	}
+}